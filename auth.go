@@ -0,0 +1,120 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+)
+
+// Authenticator applies credentials to an outgoing request.
+// The request passed to Authenticate is a clone owned by the Client, so it can be freely modified.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// ChallengeHandler is implemented by Authenticators that can recover from a 401 Unauthorized response,
+// for example by refreshing expired credentials or answering an authentication challenge.
+// HandleChallenge reports whether the request should be authenticated and sent again.
+// Each request is retried at most once, and only when its body can be replayed.
+type ChallengeHandler interface {
+	HandleChallenge(resp *http.Response) (bool, error)
+}
+
+// AuthenticatorFunc allows the use of ordinary functions as an Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BasicAuth authenticates requests using the HTTP Basic authentication scheme.
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerToken authenticates requests with a static token using the Bearer authentication scheme.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKeyHeader authenticates requests by passing the API key as the value of the given header.
+func APIKeyHeader(header, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(header, key)
+		return nil
+	})
+}
+
+// APIKeyQuery authenticates requests by passing the API key as the value of the given query parameter.
+// Any existing value for the parameter is overwritten.
+func APIKeyQuery(param, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		q := req.URL.Query()
+		q.Set(param, key)
+		req.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+type authTransport struct {
+	authenticator Authenticator
+	next          http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	authReq, err := authenticate(t.authenticator, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	handler, ok := t.authenticator.(ChallengeHandler)
+	if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return resp, nil
+	}
+	if resp.Request == nil {
+		resp.Request = authReq
+	}
+	retry, err := handler.HandleChallenge(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, newBaseError(err, ErrorTagAuthentication)
+	}
+	if !retry {
+		return resp, nil
+	}
+	// Drain the body in order to allow the connection to be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retryReq.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if authReq, err = authenticate(t.authenticator, retryReq); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(authReq)
+}
+
+// authenticate applies the credentials on a clone of the request,
+// since RoundTrippers must not modify the request they were given.
+func authenticate(authenticator Authenticator, req *http.Request) (*http.Request, error) {
+	authReq := req.Clone(req.Context())
+	if authReq.Header == nil {
+		authReq.Header = http.Header{}
+	}
+	if err := authenticator.Authenticate(authReq); err != nil {
+		return nil, newBaseError(err, ErrorTagAuthentication)
+	}
+	return authReq, nil
+}
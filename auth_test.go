@@ -0,0 +1,101 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithAuthenticator(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		params        []RequestParameter
+		wantURL       string
+		wantHeader    string
+		wantValue     string
+	}{
+		{
+			name:          "basic authentication",
+			authenticator: BasicAuth("user", "secret"),
+			wantURL:       "https://api.example.com/items",
+			wantHeader:    "Authorization",
+			wantValue:     "Basic dXNlcjpzZWNyZXQ=",
+		},
+		{
+			name:          "bearer token",
+			authenticator: BearerToken("t0k3n"),
+			wantURL:       "https://api.example.com/items",
+			wantHeader:    "Authorization",
+			wantValue:     "Bearer t0k3n",
+		},
+		{
+			name:          "API key in header",
+			authenticator: APIKeyHeader("X-Api-Key", "k3y"),
+			wantURL:       "https://api.example.com/items",
+			wantHeader:    "X-Api-Key",
+			wantValue:     "k3y",
+		},
+		{
+			name:          "API key in query",
+			authenticator: APIKeyQuery("api_key", "k3y"),
+			params:        []RequestParameter{WithQueryParameters(map[string]string{"page": "2"})},
+			wantURL:       "https://api.example.com/items?api_key=k3y&page=2",
+		},
+		{
+			name:          "authentication is skipped",
+			authenticator: BearerToken("t0k3n"),
+			params:        []RequestParameter{WithoutAuth()},
+			wantURL:       "https://api.example.com/items",
+			wantHeader:    "Authorization",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := httpmock.NewMockTransport()
+			var received *http.Request
+			mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+				received = req
+				return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+			})
+			c, err := NewWithTransport(mt).
+				WithAuthenticator(tt.authenticator).
+				WithBaseURL("https://api.example.com")
+			require.NoError(t, err)
+
+			req, err := c.prepareRequest(context.Background(), http.MethodGet, "/items", nil, tt.params...)
+			require.NoError(t, err)
+			resp, err := c.do(req)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			require.NotNil(t, received)
+			assert.Equal(t, tt.wantURL, received.URL.String())
+			if tt.wantHeader != "" {
+				assert.Equal(t, tt.wantValue, received.Header.Get(tt.wantHeader))
+			}
+			// The credentials must never leak to the request owned by the caller.
+			assert.Empty(t, req.Header.Get("Authorization"))
+			assert.NotContains(t, req.URL.RawQuery, "api_key")
+		})
+	}
+}
+
+func TestClient_WithAuthenticator_Error(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	c := NewWithTransport(mt).WithAuthenticator(AuthenticatorFunc(func(_ *http.Request) error {
+		return errors.New("credentials unavailable")
+	}))
+
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+
+	var baseErr *BaseError
+	require.ErrorAs(t, err, &baseErr)
+	assert.ErrorContains(t, err, "[httpclient][authentication] credentials unavailable")
+	assert.Equal(t, 0, mt.GetTotalCallCount())
+}
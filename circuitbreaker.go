@@ -0,0 +1,333 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is wrapped by the errors returned when a CircuitBreaker rejects a request.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a circuit.
+type CircuitState int
+
+const (
+	// CircuitClosed allows all requests.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until the open timeout elapses.
+	CircuitOpen
+	// CircuitHalfOpen allows a limited number of trial requests in order to probe the upstream.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitOpenTimeout      = 30 * time.Second
+)
+
+// CircuitBreaker fails fast with an ErrorTagCircuitOpen error when an upstream is unhealthy.
+// A separate circuit is maintained for each request host, or for each key of a custom key function.
+// A circuit opens after a number of consecutive failures, stays open for the open timeout and then
+// allows trial requests while half-open; the circuit closes after enough successful trials
+// and opens again on any failed one. Closed circuits without requests for a while are discarded,
+// along with their count of consecutive failures.
+type CircuitBreaker struct {
+	failureThreshold int
+	successThreshold int
+	openTimeout      time.Duration
+	key              func(req *http.Request) string
+	isFailure        func(resp *http.Response, err error, latency time.Duration) bool
+	onStateChange    []func(key string, from, to CircuitState)
+	now              func() time.Time
+
+	mu        sync.Mutex
+	circuits  map[string]*circuit
+	lastSweep time.Time
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	trials    int
+	openedAt  time.Time
+	// generation is incremented on every transition, so that the outcomes of requests
+	// allowed in an earlier state are ignored.
+	generation uint64
+	// inFlight and lastUsed determine whether the circuit is idle and can be discarded.
+	inFlight int
+	lastUsed time.Time
+}
+
+// NewCircuitBreaker creates a CircuitBreaker keyed by the request host.
+// By default, transport errors and 5xx responses are considered failures.
+// Requests cancelled by the caller are never considered failures.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		failureThreshold: DefaultCircuitFailureThreshold,
+		successThreshold: 1,
+		openTimeout:      DefaultCircuitOpenTimeout,
+		key: func(req *http.Request) string {
+			return req.URL.Host
+		},
+		isFailure: func(resp *http.Response, err error, _ time.Duration) bool {
+			return err != nil || resp.StatusCode >= http.StatusInternalServerError
+		},
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failures that open the circuit.
+func (b *CircuitBreaker) WithFailureThreshold(n int) *CircuitBreaker {
+	b.failureThreshold = n
+	return b
+}
+
+// WithSuccessThreshold sets the number of successful trial requests that close a half-open circuit.
+// It is also the maximum number of concurrent trial requests.
+func (b *CircuitBreaker) WithSuccessThreshold(n int) *CircuitBreaker {
+	b.successThreshold = n
+	return b
+}
+
+// WithOpenTimeout sets the period after which an open circuit becomes half-open.
+func (b *CircuitBreaker) WithOpenTimeout(timeout time.Duration) *CircuitBreaker {
+	b.openTimeout = timeout
+	return b
+}
+
+// WithKeyFunc maintains a separate circuit for each key returned by the given function.
+func (b *CircuitBreaker) WithKeyFunc(key func(req *http.Request) string) *CircuitBreaker {
+	b.key = key
+	return b
+}
+
+// WithFailureCriteria overrides the default failure criteria. Requests cancelled by the caller are still
+// never considered failures. See FailOnStatusCodes, FailOnErrorTags and FailOnLatency for common criteria.
+func (b *CircuitBreaker) WithFailureCriteria(criteria ...CircuitFailureCriterion) *CircuitBreaker {
+	b.isFailure = func(resp *http.Response, err error, latency time.Duration) bool {
+		for _, c := range criteria {
+			if c(resp, err, latency) {
+				return true
+			}
+		}
+		return false
+	}
+	return b
+}
+
+// OnStateChange registers a callback invoked on every circuit state transition.
+// Callbacks are invoked synchronously, while holding the CircuitBreaker lock, so they must not block.
+func (b *CircuitBreaker) OnStateChange(fn func(key string, from, to CircuitState)) *CircuitBreaker {
+	b.onStateChange = append(b.onStateChange, fn)
+	return b
+}
+
+// State returns the current state of the circuit for the given key.
+func (b *CircuitBreaker) State(key string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		b.refresh(key, c)
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// CircuitFailureCriterion reports whether the outcome of a request counts as a failure.
+type CircuitFailureCriterion func(resp *http.Response, err error, latency time.Duration) bool
+
+// FailOnStatusCodes considers the given response status codes as failures.
+func FailOnStatusCodes(codes ...int) CircuitFailureCriterion {
+	return func(resp *http.Response, _ error, _ time.Duration) bool {
+		if resp == nil {
+			return false
+		}
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FailOnErrorTags considers transport errors as failures. If tags are given,
+// only errors tagged with any of the given tags are considered failures.
+func FailOnErrorTags(tags ...ErrorTag) CircuitFailureCriterion {
+	return func(_ *http.Response, err error, _ time.Duration) bool {
+		if err == nil {
+			return false
+		}
+		if len(tags) == 0 {
+			return true
+		}
+		for _, tag := range tags {
+			if HasErrorTag(err, tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FailOnLatency considers requests that take longer than the given threshold as failures.
+func FailOnLatency(threshold time.Duration) CircuitFailureCriterion {
+	return func(_ *http.Response, _ error, latency time.Duration) bool {
+		return latency > threshold
+	}
+}
+
+// Middleware returns the Middleware that applies the circuit breaker.
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := b.key(req)
+			generation, err := b.allow(key)
+			if err != nil {
+				return nil, err
+			}
+			start := b.now()
+			resp, err := next.RoundTrip(req)
+			if errors.Is(err, context.Canceled) {
+				b.cancel(key, generation)
+			} else {
+				b.record(key, generation, b.isFailure(resp, err, b.now().Sub(start)))
+			}
+			return resp, err
+		})
+	}
+}
+
+// allow returns the generation of the circuit, which identifies the state in which the request was allowed.
+func (b *CircuitBreaker) allow(key string) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sweep()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	b.refresh(key, c)
+	switch c.state {
+	case CircuitOpen:
+		retryIn := c.openedAt.Add(b.openTimeout).Sub(b.now()).Round(time.Millisecond)
+		return 0, newBaseError(fmt.Errorf("%w for %s, retry in %s", ErrCircuitOpen, key, retryIn),
+			ErrorTagCircuitOpen)
+	case CircuitHalfOpen:
+		if c.trials >= b.successThreshold {
+			return 0, newBaseError(fmt.Errorf("%w for %s, trial requests in progress", ErrCircuitOpen, key),
+				ErrorTagCircuitOpen)
+		}
+		c.trials++
+	}
+	c.inFlight++
+	return c.generation, nil
+}
+
+// sweep discards the closed circuits without requests for at least keySweepInterval, at most once per interval.
+func (b *CircuitBreaker) sweep() {
+	now := b.now()
+	if now.Sub(b.lastSweep) < keySweepInterval {
+		return
+	}
+	b.lastSweep = now
+	for key, c := range b.circuits {
+		if c.state == CircuitClosed && c.inFlight == 0 && now.Sub(c.lastUsed) >= keySweepInterval {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+// finish marks the end of a request allowed by the circuit.
+func (b *CircuitBreaker) finish(c *circuit) {
+	c.inFlight--
+	c.lastUsed = b.now()
+}
+
+// record counts the outcome of a request, unless the circuit has changed state since the request was allowed.
+func (b *CircuitBreaker) record(key string, generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[key]
+	b.finish(c)
+	if c.generation != generation {
+		return
+	}
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.failureThreshold {
+			b.transition(key, c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		c.trials--
+		if failed {
+			b.transition(key, c, CircuitOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= b.successThreshold {
+			b.transition(key, c, CircuitClosed)
+		}
+	}
+}
+
+// cancel releases the trial slot of a cancelled request, without counting its outcome.
+func (b *CircuitBreaker) cancel(key string, generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[key]
+	b.finish(c)
+	if c.generation == generation && c.state == CircuitHalfOpen {
+		c.trials--
+	}
+}
+
+// refresh moves an open circuit to half-open, once the open timeout has elapsed.
+func (b *CircuitBreaker) refresh(key string, c *circuit) {
+	if c.state == CircuitOpen && !b.now().Before(c.openedAt.Add(b.openTimeout)) {
+		b.transition(key, c, CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) transition(key string, c *circuit, to CircuitState) {
+	from := c.state
+	c.state = to
+	c.failures = 0
+	c.successes = 0
+	c.trials = 0
+	c.generation++
+	if to == CircuitOpen {
+		c.openedAt = b.now()
+	}
+	for _, fn := range b.onStateChange {
+		fn(key, from, to)
+	}
+}
@@ -0,0 +1,225 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	var transitions []string
+	breaker := NewCircuitBreaker().
+		WithFailureThreshold(2).
+		WithOpenTimeout(time.Minute).
+		OnStateChange(func(key string, from, to CircuitState) {
+			transitions = append(transitions, key+": "+from.String()+" -> "+to.String())
+		})
+	breaker.now = clock.Now
+
+	status := http.StatusServiceUnavailable
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewStringResponse(status, ""), nil
+	})
+	c := NewWithTransport(mt).WithCircuitBreaker(breaker)
+	get := func(url string) (*http.Response, error) {
+		return c.Get(context.Background(), url)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := get("https://a.example.com/items")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	assert.Equal(t, CircuitOpen, breaker.State("a.example.com"))
+
+	_, err := get("https://a.example.com/items")
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	assert.True(t, HasErrorTag(err, ErrorTagCircuitOpen))
+	assert.ErrorContains(t, err, "[httpclient][circuit_open] circuit breaker is open for a.example.com, retry in 1m0s")
+	assert.Equal(t, 2, mt.GetTotalCallCount())
+
+	// Other hosts are not affected.
+	_, err = get("https://b.example.com/items")
+	require.NoError(t, err)
+
+	// A failed trial request opens the circuit again.
+	clock.Advance(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("a.example.com"))
+	_, err = get("https://a.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, CircuitOpen, breaker.State("a.example.com"))
+
+	// A successful trial request closes the circuit.
+	clock.Advance(time.Minute)
+	status = http.StatusOK
+	_, err = get("https://a.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+
+	assert.Equal(t, []string{
+		"a.example.com: closed -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> open",
+		"a.example.com: open -> half-open",
+		"a.example.com: half-open -> closed",
+	}, transitions)
+}
+
+func TestCircuitBreaker_HalfOpenLimitsTrialRequests(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	breaker := NewCircuitBreaker().WithFailureThreshold(1).WithOpenTimeout(time.Second)
+	breaker.now = clock.Now
+	generation, err := breaker.allow("host")
+	require.NoError(t, err)
+	breaker.record("host", generation, true)
+	clock.Advance(time.Second)
+
+	_, err = breaker.allow("host")
+	require.NoError(t, err)
+	_, err = breaker.allow("host")
+	assert.ErrorContains(t, err, "circuit breaker is open for host, trial requests in progress")
+}
+
+func TestCircuitBreaker_IgnoresOutcomesFromEarlierStates(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	breaker := NewCircuitBreaker().WithFailureThreshold(1).WithSuccessThreshold(2).WithOpenTimeout(time.Second)
+	breaker.now = clock.Now
+
+	slow, err := breaker.allow("host")
+	require.NoError(t, err)
+	generation, err := breaker.allow("host")
+	require.NoError(t, err)
+	breaker.record("host", generation, true)
+	clock.Advance(time.Second)
+	trial, err := breaker.allow("host")
+	require.NoError(t, err)
+
+	// The request allowed while the circuit was closed neither closes the circuit nor frees a trial slot.
+	breaker.record("host", slow, false)
+	breaker.record("host", slow, false)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("host"))
+	_, err = breaker.allow("host")
+	require.NoError(t, err)
+	_, err = breaker.allow("host")
+	assert.ErrorContains(t, err, "trial requests in progress")
+
+	breaker.record("host", trial, false)
+	assert.Equal(t, CircuitHalfOpen, breaker.State("host"))
+}
+
+func TestCircuitBreaker_CancelledRequestsAreNotFailures(t *testing.T) {
+	breaker := NewCircuitBreaker().WithFailureThreshold(1).WithFailureCriteria(FailOnErrorTags())
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(httpmock.NewErrorResponder(context.Canceled))
+	c := NewWithTransport(mt).WithCircuitBreaker(breaker)
+
+	_, err := c.Get(context.Background(), "https://a.example.com/items")
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, CircuitClosed, breaker.State("a.example.com"))
+}
+
+func TestCircuitBreaker_DiscardsIdleCircuits(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	breaker := NewCircuitBreaker().WithFailureThreshold(1).WithOpenTimeout(time.Hour)
+	breaker.now = clock.Now
+
+	for _, key := range []string{"idle", "open"} {
+		generation, err := breaker.allow(key)
+		require.NoError(t, err)
+		breaker.record(key, generation, key == "open")
+	}
+	inFlight, err := breaker.allow("in-flight")
+	require.NoError(t, err)
+	clock.Advance(keySweepInterval)
+
+	_, err = breaker.allow("new")
+	require.NoError(t, err)
+	breaker.mu.Lock()
+	assert.NotContains(t, breaker.circuits, "idle")
+	assert.Contains(t, breaker.circuits, "open")
+	assert.Contains(t, breaker.circuits, "in-flight")
+	breaker.mu.Unlock()
+	breaker.record("in-flight", inFlight, false)
+	assert.Equal(t, CircuitOpen, breaker.State("open"))
+}
+
+func TestCircuitBreaker_FailureCriteria(t *testing.T) {
+	rateLimitErr := newBaseError(ErrRateLimited, ErrorTagRateLimited)
+	tests := []struct {
+		name     string
+		criteria []CircuitFailureCriterion
+		resp     *http.Response
+		err      error
+		latency  time.Duration
+		want     bool
+	}{
+		{
+			name:     "status code matches",
+			criteria: []CircuitFailureCriterion{FailOnStatusCodes(http.StatusTooManyRequests)},
+			resp:     &http.Response{StatusCode: http.StatusTooManyRequests},
+			want:     true,
+		},
+		{
+			name:     "status code does not match",
+			criteria: []CircuitFailureCriterion{FailOnStatusCodes(http.StatusTooManyRequests)},
+			resp:     &http.Response{StatusCode: http.StatusInternalServerError},
+		},
+		{
+			name:     "any error",
+			criteria: []CircuitFailureCriterion{FailOnErrorTags()},
+			err:      errors.New("connection reset"),
+			want:     true,
+		},
+		{
+			name:     "error tag matches",
+			criteria: []CircuitFailureCriterion{FailOnErrorTags(ErrorTagRateLimited)},
+			err:      fmt.Errorf("wrapped: %w", rateLimitErr),
+			want:     true,
+		},
+		{
+			name:     "error tag does not match",
+			criteria: []CircuitFailureCriterion{FailOnErrorTags(ErrorTagCircuitOpen)},
+			err:      rateLimitErr,
+		},
+		{
+			name:     "latency exceeds threshold",
+			criteria: []CircuitFailureCriterion{FailOnStatusCodes(http.StatusBadGateway), FailOnLatency(time.Second)},
+			resp:     &http.Response{StatusCode: http.StatusOK},
+			latency:  2 * time.Second,
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := NewCircuitBreaker().WithFailureCriteria(tt.criteria...)
+			assert.Equal(t, tt.want, breaker.isFailure(tt.resp, tt.err, tt.latency))
+		})
+	}
+}
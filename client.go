@@ -9,11 +9,20 @@ import (
 )
 
 type Client struct {
-	base           http.RoundTripper
-	timeout        time.Duration
-	defaultHeaders map[string]string
-	baseURL        *url.URL
-	networkClient  *http.Client
+	base            http.RoundTripper
+	timeout         time.Duration
+	defaultHeaders  map[string]string
+	baseURL         *url.URL
+	networkClient   *http.Client
+	authenticator   Authenticator
+	signer          Signer
+	middlewares     []Middleware
+	hedging         HedgePolicy
+	hedger          hedger
+	idempotencyKeys IdempotencyKeyGenerator
+	timing          bool
+	callHooks       hooks
+	attemptHooks    hooks
 }
 
 const DefaultTimeout = 30 * time.Second
@@ -28,14 +37,15 @@ func NewWithTransport(transport http.RoundTripper) *Client {
 	if transport == nil {
 		panic("transport must be non-nil")
 	}
-	return &Client{
+	c := &Client{
 		timeout: DefaultTimeout,
 		base:    transport,
-		networkClient: &http.Client{
-			Timeout:   DefaultTimeout,
-			Transport: transport,
-		},
 	}
+	c.networkClient = &http.Client{
+		Timeout:   DefaultTimeout,
+		Transport: RoundTripperFunc(c.roundTrip),
+	}
+	return c
 }
 
 func (c *Client) WithTimeout(timeout time.Duration) *Client {
@@ -61,6 +71,138 @@ func (c *Client) WithDefaultHeaders(headers map[string]string) *Client {
 	return c
 }
 
+// WithAuthenticator configures the Authenticator that applies credentials on every Request.
+// Credentials are applied right before the Request is passed to the base transport,
+// so they are never part of the default headers. Use the WithoutAuth functional option parameter
+// in order to skip authentication on a per-request basis.
+func (c *Client) WithAuthenticator(authenticator Authenticator) *Client {
+	c.authenticator = authenticator
+	return c
+}
+
+// WithSigner configures the Signer that signs every Request.
+// Requests are signed after the Authenticator has been applied, right before they are passed to the base transport.
+func (c *Client) WithSigner(signer Signer) *Client {
+	c.signer = signer
+	return c
+}
+
+// WithMiddleware appends the given middlewares to the Client middleware chain.
+// Middlewares are invoked in the order of registration; the first one registered is the outermost.
+func (c *Client) WithMiddleware(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+// WithRateLimiter adds the RateLimiter Middleware in the Client middleware chain.
+// Multiple limiters can be combined, e.g. a global one and a per-host one.
+func (c *Client) WithRateLimiter(limiter *RateLimiter) *Client {
+	return c.WithMiddleware(limiter.Middleware())
+}
+
+// WithCircuitBreaker adds the CircuitBreaker Middleware in the Client middleware chain.
+func (c *Client) WithCircuitBreaker(breaker *CircuitBreaker) *Client {
+	return c.WithMiddleware(breaker.Middleware())
+}
+
+// WithConcurrencyLimiter adds the ConcurrencyLimiter Middleware in the Client middleware chain.
+func (c *Client) WithConcurrencyLimiter(limiter *ConcurrencyLimiter) *Client {
+	return c.WithMiddleware(limiter.Middleware())
+}
+
+// WithHedging configures hedged requests for the safe HTTP methods, according to the given HedgePolicy.
+// The policy can be overridden on a per-request basis using the WithHedging functional option parameter.
+func (c *Client) WithHedging(policy HedgePolicy) *Client {
+	c.hedging = policy
+	return c
+}
+
+// WithCoalescer adds the Coalescer Middleware in the Client middleware chain.
+func (c *Client) WithCoalescer(coalescer *Coalescer) *Client {
+	return c.WithMiddleware(coalescer.Middleware())
+}
+
+// WithRevalidator adds the Revalidator Middleware in the Client middleware chain,
+// in order to send conditional GET requests automatically.
+func (c *Client) WithRevalidator(revalidator *Revalidator) *Client {
+	return c.WithMiddleware(revalidator.Middleware())
+}
+
+// WithRequestLogger adds the RequestLogger Middleware in the Client middleware chain.
+func (c *Client) WithRequestLogger(logger *RequestLogger) *Client {
+	return c.WithMiddleware(logger.Middleware())
+}
+
+// WithCurlLogger adds the CurlLogger Middleware in the Client middleware chain.
+func (c *Client) WithCurlLogger(logger *CurlLogger) *Client {
+	return c.WithMiddleware(logger.Middleware())
+}
+
+// WithHARRecorder adds the HARRecorder Middleware in the Client middleware chain.
+func (c *Client) WithHARRecorder(recorder *HARRecorder) *Client {
+	return c.WithMiddleware(recorder.Middleware())
+}
+
+// WithTracer adds the Tracer Middleware in the Client middleware chain.
+// Register it after the middlewares that should be covered by the client spans.
+func (c *Client) WithTracer(tracer *Tracer) *Client {
+	return c.WithMiddleware(tracer.Middleware())
+}
+
+// WithMetrics feeds the given MetricsRecorder, e.g. a PrometheusCollector, with the measurements of every request.
+func (c *Client) WithMetrics(recorder MetricsRecorder) *Client {
+	return c.WithMiddleware(MetricsMiddleware(recorder))
+}
+
+// WithTiming collects the Timing of every request, which is available through TimingFromResponse.
+// Timing can also be enabled on a per-request basis using the WithTiming functional option parameter.
+func (c *Client) WithTiming() *Client {
+	c.timing = true
+	return c
+}
+
+// WithIdempotencyKeys generates an idempotency key for every POST and PATCH call, e.g. using UUIDv4 or UUIDv7.
+// The key is sent in the Idempotency-Key header and stays the same across all attempts of the call.
+// Requests that already have the header, or use the WithIdempotencyKey functional option parameter, keep their key.
+func (c *Client) WithIdempotencyKeys(generator IdempotencyKeyGenerator) *Client {
+	c.idempotencyKeys = generator
+	return c
+}
+
+// OnRequest registers a RequestHook. PerCall hooks are invoked once the request has been prepared,
+// before any attempt is made; PerAttempt hooks are invoked on a copy of the request for every attempt,
+// before the middleware chain. Hooks of the same scope are invoked in the order of registration.
+func (c *Client) OnRequest(scope HookScope, hook RequestHook) *Client {
+	h := c.hooksFor(scope)
+	h.request = append(h.request, hook)
+	return c
+}
+
+// OnResponse registers a ResponseHook. PerAttempt hooks are invoked as soon as the response of each attempt
+// has passed through the middleware chain; PerCall hooks are invoked once the call returns a response.
+// Hooks of the same scope are invoked in the order of registration.
+func (c *Client) OnResponse(scope HookScope, hook ResponseHook) *Client {
+	h := c.hooksFor(scope)
+	h.response = append(h.response, hook)
+	return c
+}
+
+// OnError registers an ErrorHook. PerAttempt hooks are invoked for every failed attempt,
+// while PerCall hooks are invoked once when the call returns an error.
+// Hooks of the same scope are invoked in the order of registration.
+func (c *Client) OnError(scope HookScope, hook ErrorHook) *Client {
+	h := c.hooksFor(scope)
+	h.error = append(h.error, hook)
+	return c
+}
+
+func (c *Client) hooksFor(scope HookScope) *hooks {
+	if scope == PerAttempt {
+		return &c.attemptHooks
+	}
+	return &c.callHooks
+}
+
 func (c *Client) WithBaseURL(baseURL string) (*Client, error) {
 	base, err := url.Parse(baseURL)
 	if err != nil {
@@ -84,7 +226,7 @@ func (c *Client) Get(ctx context.Context, url string, parameters ...RequestParam
 	if err != nil {
 		return nil, err
 	}
-	return c.networkClient.Do(req)
+	return c.do(req)
 }
 
 func (c *Client) prepareRequest(ctx context.Context, method string, rawURL string, body io.Reader, parameters ...RequestParameter) (*http.Request, error) {
@@ -107,6 +249,12 @@ func (c *Client) prepareRequest(ctx context.Context, method string, rawURL strin
 	if err != nil {
 		return nil, err
 	}
+	if err := setIdempotencyKey(req, c.idempotencyKeys); err != nil {
+		return nil, err
+	}
+	if c.timing || requestParametersFromContext(req.Context()).timing {
+		req = withTimingTrace(req, time.Now)
+	}
 	return req, nil
 }
 
@@ -116,7 +264,7 @@ func (c *Client) Head(ctx context.Context, url string, parameters ...RequestPara
 	if err != nil {
 		return nil, err
 	}
-	return c.networkClient.Do(req)
+	return c.do(req)
 }
 
 func (c *Client) Post(ctx context.Context, url string, body io.Reader, parameters ...RequestParameter) (*http.Response, error) {
@@ -124,7 +272,7 @@ func (c *Client) Post(ctx context.Context, url string, body io.Reader, parameter
 	if err != nil {
 		return nil, err
 	}
-	return c.networkClient.Do(req)
+	return c.do(req)
 }
 
 func (c *Client) Patch(ctx context.Context, url string, body io.Reader, parameters ...RequestParameter) (*http.Response, error) {
@@ -132,7 +280,7 @@ func (c *Client) Patch(ctx context.Context, url string, body io.Reader, paramete
 	if err != nil {
 		return nil, err
 	}
-	return c.networkClient.Do(req)
+	return c.do(req)
 }
 
 func (c *Client) Delete(ctx context.Context, url string, parameters ...RequestParameter) (*http.Response, error) {
@@ -140,5 +288,59 @@ func (c *Client) Delete(ctx context.Context, url string, parameters ...RequestPa
 	if err != nil {
 		return nil, err
 	}
-	return c.networkClient.Do(req)
+	return c.do(req)
+}
+
+func (c *Client) do(req *http.Request) (resp *http.Response, err error) {
+	req, call := withCallTrace(req)
+	defer func() {
+		call.finish(resp, err)
+	}()
+	if err := c.callHooks.beforeSend(req); err != nil {
+		c.callHooks.failed(req, err)
+		return nil, err
+	}
+	resp, err = c.networkClient.Do(req)
+	if err != nil {
+		if key := req.Header.Get(IdempotencyKeyHeader); key != "" {
+			err = &idempotencyKeyError{key: key, err: err}
+		}
+		c.callHooks.failed(req, err)
+		return resp, err
+	}
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	if trace, ok := req.Context().Value(timingKey{}).(*timingTrace); ok {
+		resp.Body = &timedBody{ReadCloser: resp.Body, trace: trace}
+	}
+	c.callHooks.received(req, resp)
+	return resp, nil
+}
+
+// roundTrip is the transport of the underlying net/http Client.
+// The base transport is resolved on every call, so changes made through WithBaseTransport take effect immediately.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	params := requestParametersFromContext(req.Context())
+	var rt http.RoundTripper = c.base
+	if c.signer != nil {
+		rt = &signTransport{signer: c.signer, next: rt}
+	}
+	if c.authenticator != nil && !params.skipAuth {
+		rt = &authTransport{authenticator: c.authenticator, next: rt}
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	if !c.attemptHooks.empty() {
+		rt = &hookTransport{hooks: &c.attemptHooks, next: rt}
+	}
+	hedging := c.hedging
+	if params.hedging != nil {
+		hedging = *params.hedging
+	}
+	if hedgeable(req, hedging) {
+		return c.hedger.roundTrip(rt, req, hedging)
+	}
+	return rt.RoundTrip(req)
 }
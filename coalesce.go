@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// credentialHeaders are always compared by a Coalescer, so that responses are never shared across credentials.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// Coalescer shares a single upstream call among concurrent identical GET and HEAD requests.
+// Requests are identical when they have the same method, resolved URL, credentials and values for the configured
+// headers. Requests that skip the Client Authenticator are never coalesced with authenticated ones.
+// The response body of the shared call is read in memory and every caller receives its own readable copy.
+//
+// The shared call is not bound to the context of any single caller: each caller stops waiting when its own context
+// is done, and the shared call is cancelled once every caller has stopped waiting.
+type Coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// NewCoalescer creates a Coalescer that also takes into account the values of the given headers
+// when comparing requests, e.g. `Accept` or `Accept-Language`.
+func NewCoalescer(headers ...string) *Coalescer {
+	return &Coalescer{headers: headers, calls: make(map[string]*coalescedCall)}
+}
+
+type coalescedResponse struct {
+	resp *http.Response
+	body []byte
+}
+
+type coalescedCall struct {
+	done   chan struct{}
+	res    coalescedResponse
+	err    error
+	cancel context.CancelFunc
+	// waiters is guarded by the Coalescer lock.
+	waiters int
+}
+
+// Middleware returns the Middleware that coalesces identical requests.
+func (c *Coalescer) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead ||
+				req.Body != nil && req.Body != http.NoBody {
+				return next.RoundTrip(req)
+			}
+			key := c.key(req)
+			call := c.join(key, next, req)
+			select {
+			case <-req.Context().Done():
+				c.leave(key, call)
+				return nil, req.Context().Err()
+			case <-call.done:
+				if call.err != nil {
+					return nil, call.err
+				}
+				shared := call.res
+				resp := *shared.resp
+				resp.Header = shared.resp.Header.Clone()
+				resp.Trailer = shared.resp.Trailer.Clone()
+				resp.Body = io.NopCloser(bytes.NewReader(shared.body))
+				resp.Request = req
+				return &resp, nil
+			}
+		})
+	}
+}
+
+// join returns the in-flight call for the key, starting it if there is none.
+func (c *Coalescer) join(key string, next http.RoundTripper, req *http.Request) *coalescedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call, ok := c.calls[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go func() {
+			call.res, call.err = roundTripShared(next, req.WithContext(ctx))
+			c.mu.Lock()
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+			c.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	return call
+}
+
+// leave cancels the call once no caller is waiting for it.
+func (c *Coalescer) leave(key string, call *coalescedCall) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call.waiters--
+	if call.waiters == 0 {
+		call.cancel()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+	}
+}
+
+func (c *Coalescer) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method + " " + req.URL.String())
+	if requestParametersFromContext(req.Context()).skipAuth {
+		b.WriteString("\nskip-auth")
+	}
+	for _, h := range append(credentialHeaders, c.headers...) {
+		b.WriteString("\n" + strings.ToLower(h) + ":" + strings.Join(req.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// roundTripShared sends the request on behalf of all callers and reads the response body in memory.
+func roundTripShared(next http.RoundTripper, req *http.Request) (coalescedResponse, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return coalescedResponse{}, err
+	}
+	body, err := InterceptResponseBody(resp)
+	if err != nil {
+		return coalescedResponse{}, err
+	}
+	return coalescedResponse{resp: resp, body: body}, nil
+}
@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCoalescingClient(calls *atomic.Int32) *Client {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		// Allow concurrent callers to pile up while the request is in flight.
+		time.Sleep(20 * time.Millisecond)
+		resp := httpmock.NewStringResponse(http.StatusOK, "hot key "+req.Header.Get("Accept-Language"))
+		resp.Header.Set("Content-Type", "text/plain")
+		return resp, nil
+	})
+	return NewWithTransport(mt).WithCoalescer(NewCoalescer("Accept-Language"))
+}
+
+func TestClient_WithCoalescer(t *testing.T) {
+	calls := &atomic.Int32{}
+	c := newCoalescingClient(calls)
+
+	var wg sync.WaitGroup
+	responses := make([]*http.Response, 10)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "https://api.example.com/keys/1",
+				WithHeaders(map[string]string{"Accept-Language": "en"}))
+			assert.NoError(t, err)
+			responses[i] = resp
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, resp := range responses {
+		require.NotNil(t, resp)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hot key en", string(MustInterceptResponseBody(resp)))
+	}
+	// Every caller has its own copy of the headers.
+	responses[1].Header.Set("Content-Type", "modified")
+	assert.Equal(t, "text/plain", responses[0].Header.Get("Content-Type"))
+}
+
+func TestClient_WithCoalescer_DistinctRequests(t *testing.T) {
+	calls := &atomic.Int32{}
+	c := newCoalescingClient(calls)
+
+	var wg sync.WaitGroup
+	requests := []func() (*http.Response, error){
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1")
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/2")
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1",
+				WithHeaders(map[string]string{"Accept-Language": "fr"}))
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1",
+				WithHeaders(map[string]string{"Authorization": "Bearer a"}))
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1",
+				WithHeaders(map[string]string{"Authorization": "Bearer b"}))
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1",
+				WithHeaders(map[string]string{"Cookie": "session=a"}))
+		},
+		func() (*http.Response, error) {
+			return c.Get(context.Background(), "https://api.example.com/keys/1", WithoutAuth())
+		},
+		func() (*http.Response, error) {
+			return c.Post(context.Background(), "https://api.example.com/keys/1", strings.NewReader("{}"))
+		},
+		func() (*http.Response, error) {
+			return c.Post(context.Background(), "https://api.example.com/keys/1", strings.NewReader("{}"))
+		},
+	}
+	for _, send := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := send()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(len(requests)), calls.Load())
+}
+
+func TestClient_WithCoalescer_Deadlines(t *testing.T) {
+	calls := &atomic.Int32{}
+	c := newCoalescingClient(calls)
+
+	// The shared call outlives the earliest deadline, as long as another caller is waiting.
+	short, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.Get(short, "https://api.example.com/keys/1")
+		errs <- err
+	}()
+	time.Sleep(time.Millisecond)
+	resp, err := c.Get(context.Background(), "https://api.example.com/keys/1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "hot key ", string(MustInterceptResponseBody(resp)))
+	assert.ErrorIs(t, <-errs, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCoalescer_CancelsCallWithoutWaiters(t *testing.T) {
+	cancelled := make(chan struct{})
+	next := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		close(cancelled)
+		return nil, req.Context().Err()
+	})
+	rt := NewCoalescer().Middleware()(next)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com/keys/1", nil)
+	require.NoError(t, err)
+
+	_, err = rt.RoundTrip(req)
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the shared call was not cancelled")
+	}
+}
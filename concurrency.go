@@ -0,0 +1,337 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimited is wrapped by the errors returned when a ConcurrencyLimiter queue is full.
+var ErrConcurrencyLimited = errors.New("concurrency limit exceeded")
+
+// ConcurrencyLimiter bounds the number of in-flight requests (bulkhead). By default, a single limit
+// is shared by all requests. Requests over the limit wait in a FIFO queue until a slot is released
+// or the request context is done. A slot is released once the response body has been read completely
+// or closed, so the limit also applies to streaming responses and downloads.
+// Keys without requests are discarded after a minute of inactivity, along with their adaptive limit.
+type ConcurrencyLimiter struct {
+	maxInFlight int
+	maxQueue    int
+	key         func(req *http.Request) string
+	algorithm   LimitAlgorithm
+	now         func() time.Time
+
+	mu        sync.Mutex
+	bulkheads map[string]*bulkhead
+	lastSweep time.Time
+}
+
+// ConcurrencyStats is a snapshot of the state of a ConcurrencyLimiter key.
+type ConcurrencyStats struct {
+	Key      string
+	Limit    int
+	InFlight int
+	Queued   int
+}
+
+// NewConcurrencyLimiter creates a ConcurrencyLimiter that allows at most `maxInFlight` concurrent requests.
+// When an adaptive limit is configured, `maxInFlight` is the initial limit.
+func NewConcurrencyLimiter(maxInFlight int) *ConcurrencyLimiter {
+	if maxInFlight < 1 {
+		panic("maxInFlight must be positive")
+	}
+	return &ConcurrencyLimiter{
+		maxInFlight: maxInFlight,
+		now:         time.Now,
+		bulkheads:   make(map[string]*bulkhead),
+	}
+}
+
+// PerHost maintains a separate limit for each request host.
+func (l *ConcurrencyLimiter) PerHost() *ConcurrencyLimiter {
+	return l.WithKeyFunc(func(req *http.Request) string {
+		return req.URL.Host
+	})
+}
+
+// WithKeyFunc maintains a separate limit for each key returned by the given function.
+func (l *ConcurrencyLimiter) WithKeyFunc(key func(req *http.Request) string) *ConcurrencyLimiter {
+	l.key = key
+	return l
+}
+
+// WithMaxQueue rejects requests with an ErrorTagConcurrencyLimited error,
+// when `maxQueue` requests are already waiting for a slot.
+func (l *ConcurrencyLimiter) WithMaxQueue(maxQueue int) *ConcurrencyLimiter {
+	l.maxQueue = maxQueue
+	return l
+}
+
+// WithAdaptiveLimit adjusts the limit after every request, based on the observed latency and failures.
+// See AIMDLimit and GradientLimit.
+func (l *ConcurrencyLimiter) WithAdaptiveLimit(algorithm LimitAlgorithm) *ConcurrencyLimiter {
+	l.algorithm = algorithm
+	return l
+}
+
+// Stats returns a snapshot of every key of the limiter, sorted by key.
+func (l *ConcurrencyLimiter) Stats() []ConcurrencyStats {
+	l.mu.Lock()
+	stats := make([]ConcurrencyStats, 0, len(l.bulkheads))
+	for k, b := range l.bulkheads {
+		stats = append(stats, b.stats(k))
+	}
+	l.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// Middleware returns the Middleware that applies the concurrency limit.
+func (l *ConcurrencyLimiter) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := ""
+			if l.key != nil {
+				key = l.key(req)
+			}
+			b := l.bulkhead(key)
+			if err := b.acquire(req.Context(), key); err != nil {
+				l.done(b)
+				return nil, err
+			}
+			start := l.now()
+			resp, err := next.RoundTrip(req)
+			failed := (err != nil && !errors.Is(err, context.Canceled)) ||
+				(resp != nil && resp.StatusCode >= http.StatusInternalServerError)
+			release := func() {
+				b.release(l.now().Sub(start), failed)
+				l.done(b)
+			}
+			if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+				release()
+				return resp, err
+			}
+			resp.Body = &slotBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		})
+	}
+}
+
+// bulkhead returns the bulkhead of the key, creating it if needed, and marks it as in use until done is called.
+func (l *ConcurrencyLimiter) bulkhead(key string) *bulkhead {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= keySweepInterval {
+		l.lastSweep = now
+		for k, b := range l.bulkheads {
+			if b.users == 0 && now.Sub(b.lastUsed) >= keySweepInterval {
+				delete(l.bulkheads, k)
+			}
+		}
+	}
+	b, ok := l.bulkheads[key]
+	if !ok {
+		b = &bulkhead{limit: float64(l.maxInFlight), maxQueue: l.maxQueue, algorithm: l.algorithm}
+		l.bulkheads[key] = b
+	}
+	b.users++
+	return b
+}
+
+func (l *ConcurrencyLimiter) done(b *bulkhead) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.users--
+	b.lastUsed = l.now()
+}
+
+// slotBody releases the slot of the request once the body has been read completely or closed.
+type slotBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *slotBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+type bulkhead struct {
+	maxQueue  int
+	algorithm LimitAlgorithm
+
+	// users and lastUsed are guarded by the ConcurrencyLimiter lock.
+	users    int
+	lastUsed time.Time
+
+	mu         sync.Mutex
+	limit      float64
+	inFlight   int
+	minLatency time.Duration
+	waiters    []chan struct{}
+}
+
+func (b *bulkhead) acquire(ctx context.Context, key string) error {
+	b.mu.Lock()
+	if b.inFlight < int(b.limit) && len(b.waiters) == 0 {
+		b.inFlight++
+		b.mu.Unlock()
+		return nil
+	}
+	if b.maxQueue > 0 && len(b.waiters) >= b.maxQueue {
+		b.mu.Unlock()
+		err := fmt.Errorf("%w, %d requests queued", ErrConcurrencyLimited, b.maxQueue)
+		if key != "" {
+			err = fmt.Errorf("%w for %s, %d requests queued", ErrConcurrencyLimited, key, b.maxQueue)
+		}
+		return newBaseError(err, ErrorTagConcurrencyLimited)
+	}
+	ready := make(chan struct{})
+	b.waiters = append(b.waiters, ready)
+	b.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, w := range b.waiters {
+			if w == ready {
+				b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// The slot was granted concurrently with the cancellation, so it must be handed over.
+		b.inFlight--
+		b.dispatch()
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release(latency time.Duration, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.algorithm != nil {
+		if b.minLatency == 0 || latency < b.minLatency {
+			b.minLatency = latency
+		}
+		b.limit = b.algorithm.NextLimit(b.limit, LimitSample{
+			Latency:    latency,
+			MinLatency: b.minLatency,
+			InFlight:   b.inFlight,
+			Failed:     failed,
+		})
+	}
+	b.inFlight--
+	b.dispatch()
+}
+
+// dispatch grants slots to queued requests in FIFO order. The lock must be held.
+func (b *bulkhead) dispatch() {
+	for len(b.waiters) > 0 && b.inFlight < int(b.limit) {
+		b.inFlight++
+		close(b.waiters[0])
+		b.waiters = b.waiters[1:]
+	}
+}
+
+func (b *bulkhead) stats(key string) ConcurrencyStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return ConcurrencyStats{Key: key, Limit: int(b.limit), InFlight: b.inFlight, Queued: len(b.waiters)}
+}
+
+// LimitSample describes a completed request, for the purpose of adjusting an adaptive concurrency limit.
+type LimitSample struct {
+	Latency time.Duration
+	// MinLatency is the lowest latency observed so far, which approximates the latency of an idle upstream.
+	MinLatency time.Duration
+	InFlight   int
+	// Failed is set for transport errors and 5xx responses.
+	Failed bool
+}
+
+// LimitAlgorithm calculates the next concurrency limit after each request.
+type LimitAlgorithm interface {
+	NextLimit(current float64, sample LimitSample) float64
+}
+
+// AIMDLimit increases the limit additively by one for every `limit` successful requests and decreases it
+// multiplicatively when a request fails or its latency exceeds LatencyThreshold.
+type AIMDLimit struct {
+	Min              int
+	Max              int
+	LatencyThreshold time.Duration
+	// BackoffRatio is the multiplier applied to the limit on a decrease. Defaults to 0.9.
+	BackoffRatio float64
+}
+
+func (a AIMDLimit) NextLimit(current float64, sample LimitSample) float64 {
+	if sample.Failed || (a.LatencyThreshold > 0 && sample.Latency > a.LatencyThreshold) {
+		ratio := a.BackoffRatio
+		if ratio <= 0 || ratio >= 1 {
+			ratio = 0.9
+		}
+		return clampLimit(math.Floor(current*ratio), a.Min, a.Max)
+	}
+	return clampLimit(current+1/current, a.Min, a.Max)
+}
+
+// GradientLimit adjusts the limit proportionally to the ratio of the minimum latency to the current latency,
+// so that the limit shrinks as soon as requests start queueing at the upstream.
+// A headroom of the square root of the limit allows the limit to grow while latency is stable.
+type GradientLimit struct {
+	Min int
+	Max int
+	// Tolerance is the ratio by which the latency may exceed the minimum latency before the limit shrinks.
+	// Defaults to 1.5.
+	Tolerance float64
+}
+
+func (g GradientLimit) NextLimit(current float64, sample LimitSample) float64 {
+	if sample.Failed {
+		return clampLimit(current/2, g.Min, g.Max)
+	}
+	tolerance := g.Tolerance
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	gradient := 1.0
+	if sample.Latency > 0 {
+		gradient = math.Max(0.5, math.Min(1, tolerance*float64(sample.MinLatency)/float64(sample.Latency)))
+	}
+	next := current*gradient + math.Sqrt(current)
+	// Smooth the changes in order to avoid oscillations.
+	return clampLimit(0.8*current+0.2*next, g.Min, g.Max)
+}
+
+func clampLimit(limit float64, minLimit, maxLimit int) float64 {
+	if minLimit < 1 {
+		minLimit = 1
+	}
+	if maxLimit > 0 && limit > float64(maxLimit) {
+		return float64(maxLimit)
+	}
+	return math.Max(limit, float64(minLimit))
+}
@@ -0,0 +1,209 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func blockingClient(t *testing.T, limiter *ConcurrencyLimiter) (*Client, chan struct{}) {
+	t.Helper()
+	unblock := make(chan struct{})
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		<-unblock
+		return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+	})
+	return NewWithTransport(mt).WithConcurrencyLimiter(limiter), unblock
+}
+
+func waitForStats(t *testing.T, limiter *ConcurrencyLimiter, want []ConcurrencyStats) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, limiter.Stats())
+	}, time.Second, time.Millisecond, "got %v", limiter.Stats())
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2).PerHost()
+	c, unblock := blockingClient(t, limiter)
+
+	var wg sync.WaitGroup
+	for _, host := range []string{"a", "a", "a", "a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "https://"+host+".example.com")
+			if assert.NoError(t, err) {
+				assert.NoError(t, resp.Body.Close())
+			}
+		}()
+	}
+	waitForStats(t, limiter, []ConcurrencyStats{
+		{Key: "a.example.com", Limit: 2, InFlight: 2, Queued: 2},
+		{Key: "b.example.com", Limit: 2, InFlight: 1},
+	})
+
+	close(unblock)
+	wg.Wait()
+	waitForStats(t, limiter, []ConcurrencyStats{
+		{Key: "a.example.com", Limit: 2},
+		{Key: "b.example.com", Limit: 2},
+	})
+}
+
+func TestConcurrencyLimiter_QueueBoundedByContext(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	c, unblock := blockingClient(t, limiter)
+	defer close(unblock)
+	go func() {
+		_, _ = c.Get(context.Background(), "https://api.example.com")
+	}()
+	waitForStats(t, limiter, []ConcurrencyStats{{Limit: 1, InFlight: 1}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(ctx, "https://api.example.com")
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	waitForStats(t, limiter, []ConcurrencyStats{{Limit: 1, InFlight: 1}})
+}
+
+func TestConcurrencyLimiter_WithMaxQueue(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1).WithMaxQueue(1)
+	c, unblock := blockingClient(t, limiter)
+	defer close(unblock)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _ = c.Get(context.Background(), "https://api.example.com")
+		}()
+	}
+	waitForStats(t, limiter, []ConcurrencyStats{{Limit: 1, InFlight: 1, Queued: 1}})
+
+	_, err := c.Get(context.Background(), "https://api.example.com")
+
+	require.ErrorIs(t, err, ErrConcurrencyLimited)
+	assert.True(t, HasErrorTag(err, ErrorTagConcurrencyLimited))
+}
+
+func TestConcurrencyLimiter_ReleasesSlotWhenBodyIsClosed(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(httpmock.NewStringResponder(http.StatusOK, "OK"))
+	c := NewWithTransport(mt).WithConcurrencyLimiter(limiter)
+
+	resp, err := c.Get(context.Background(), "https://api.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []ConcurrencyStats{{Limit: 1, InFlight: 1}}, limiter.Stats())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(ctx, "https://api.example.com")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(body))
+	assert.Equal(t, []ConcurrencyStats{{Limit: 1}}, limiter.Stats())
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, []ConcurrencyStats{{Limit: 1}}, limiter.Stats())
+}
+
+func TestConcurrencyLimiter_DiscardsIdleKeys(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := NewConcurrencyLimiter(1).PerHost()
+	limiter.now = clock.Now
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(httpmock.NewStringResponder(http.StatusOK, "OK"))
+	c := NewWithTransport(mt).WithConcurrencyLimiter(limiter)
+	get := func(host string) *http.Response {
+		resp, err := c.Get(context.Background(), "https://"+host+".example.com")
+		require.NoError(t, err)
+		return resp
+	}
+
+	require.NoError(t, get("a").Body.Close())
+	open := get("b")
+	clock.Advance(keySweepInterval)
+	require.NoError(t, get("c").Body.Close())
+
+	// b.example.com is still in use, since its response body has not been closed.
+	assert.Equal(t, []ConcurrencyStats{
+		{Key: "b.example.com", Limit: 1, InFlight: 1},
+		{Key: "c.example.com", Limit: 1},
+	}, limiter.Stats())
+	require.NoError(t, open.Body.Close())
+}
+
+func TestConcurrencyLimiter_AdaptiveLimit(t *testing.T) {
+	b := &bulkhead{limit: 10, algorithm: AIMDLimit{Min: 2, Max: 20, LatencyThreshold: 100 * time.Millisecond}}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.acquire(context.Background(), ""))
+		b.release(time.Second, false)
+	}
+	assert.Equal(t, 7, b.stats("").Limit)
+
+	for i := 0; i < 30; i++ {
+		require.NoError(t, b.acquire(context.Background(), ""))
+		b.release(10*time.Millisecond, false)
+	}
+	assert.Equal(t, 10, b.stats("").Limit)
+}
+
+func TestLimitAlgorithms(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm LimitAlgorithm
+		current   float64
+		sample    LimitSample
+		want      float64
+	}{
+		{
+			name:      "AIMD increases additively",
+			algorithm: AIMDLimit{Max: 20},
+			current:   10,
+			sample:    LimitSample{Latency: time.Millisecond},
+			want:      10.1,
+		},
+		{
+			name:      "AIMD decreases multiplicatively on failure",
+			algorithm: AIMDLimit{BackoffRatio: 0.5},
+			current:   10,
+			sample:    LimitSample{Failed: true},
+			want:      5,
+		},
+		{
+			name:      "AIMD respects the minimum limit",
+			algorithm: AIMDLimit{Min: 8, LatencyThreshold: time.Millisecond},
+			current:   8,
+			sample:    LimitSample{Latency: time.Second},
+			want:      8,
+		},
+		{
+			name:      "gradient grows while latency is stable",
+			algorithm: GradientLimit{Max: 100},
+			current:   16,
+			sample:    LimitSample{Latency: 10 * time.Millisecond, MinLatency: 10 * time.Millisecond},
+			want:      16.8,
+		},
+		{
+			name:      "gradient shrinks when latency rises",
+			algorithm: GradientLimit{},
+			current:   16,
+			sample:    LimitSample{Latency: 100 * time.Millisecond, MinLatency: 10 * time.Millisecond},
+			want:      15.2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, tt.algorithm.NextLimit(tt.current, tt.sample), 0.001)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToCurl returns a curl command equivalent to the request, in order to reproduce it from a shell.
+// The command includes the method, the full URL with its query, every header and the body.
+// Bodies that are not replayable through GetBody are read and restored in the request.
+// Values are not redacted; use a CurlLogger in order to log the commands of a Client safely.
+func ToCurl(req *http.Request) (string, error) {
+	body, err := curlRequestBody(req, true)
+	if err != nil {
+		return "", err
+	}
+	return curlCommand(req, body, nil), nil
+}
+
+// CurlLogger logs the curl equivalent of every request through a slog.Logger, under the `curl` attribute,
+// before the request is sent. The values of sensitive headers and query parameters are replaced with RedactedValue.
+// Only replayable request bodies are included, so that the request is never altered.
+type CurlLogger struct {
+	logger   *slog.Logger
+	level    slog.Level
+	redactor *redactor
+}
+
+// NewCurlLogger creates a CurlLogger that writes to the given slog.Logger, or to slog.Default() if nil.
+// Commands are logged at the Debug level and DefaultRedactedHeaders and DefaultRedactedQueryParameters are redacted.
+func NewCurlLogger(logger *slog.Logger) *CurlLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &CurlLogger{
+		logger:   logger,
+		level:    slog.LevelDebug,
+		redactor: newRedactor(),
+	}
+}
+
+// WithLevel sets the level of the log records.
+func (l *CurlLogger) WithLevel(level slog.Level) *CurlLogger {
+	l.level = level
+	return l
+}
+
+// WithRedactedHeaders adds to the headers whose values are redacted.
+func (l *CurlLogger) WithRedactedHeaders(names ...string) *CurlLogger {
+	l.redactor.addHeaders(names...)
+	return l
+}
+
+// WithRedactedQueryParameters adds to the query parameters whose values are redacted.
+func (l *CurlLogger) WithRedactedQueryParameters(names ...string) *CurlLogger {
+	l.redactor.addQueryParameters(names...)
+	return l
+}
+
+// Middleware returns the Middleware that logs the curl commands.
+func (l *CurlLogger) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			if l.logger.Enabled(ctx, l.level) {
+				body, err := curlRequestBody(req, false)
+				if err == nil {
+					l.logger.LogAttrs(ctx, l.level, "http request", slog.String("curl", curlCommand(req, body, l.redactor)))
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// curlRequestBody returns the request body, or nil if there is none. Bodies that are not replayable
+// are only read, and restored, if consume is set; otherwise they are omitted.
+func curlRequestBody(req *http.Request, consume bool) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	if !consume {
+		return nil, nil
+	}
+	return InterceptRequestBody(req)
+}
+
+// curlCommand formats the curl command of the request, redacting sensitive values unless the redactor is nil.
+func curlCommand(req *http.Request, body []byte, r *redactor) string {
+	var args []string
+	switch {
+	case req.Method == http.MethodHead:
+		args = append(args, "--head")
+	case req.Method != "" && (req.Method != http.MethodGet || body != nil):
+		args = append(args, "-X", req.Method)
+	}
+	args = append(args, shellQuote(r.url(req.URL).String()))
+
+	headers := req.Header.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if req.Host != "" && req.Host != req.URL.Host {
+		headers.Set("Host", req.Host)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			args = append(args, "-H", shellQuote(name+": "+r.header(name, value)))
+		}
+	}
+
+	cmd := "curl " + strings.Join(args, " ")
+	switch {
+	case body == nil:
+		return cmd
+	case utf8.Valid(body) && bytes.IndexByte(body, 0) < 0:
+		return cmd + " --data-binary " + shellQuote(string(body))
+	default:
+		// Binary bodies cannot be passed as shell arguments, so they are piped through base64.
+		return "echo " + shellQuote(base64.StdEncoding.EncodeToString(body)) + " | base64 -d | " + cmd + " --data-binary @-"
+	}
+}
+
+// shellQuote quotes s for POSIX shells, so that it is passed as a single argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
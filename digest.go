@@ -0,0 +1,232 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestAlgorithms are the supported RFC 7616 algorithms, in order of preference.
+var digestAlgorithms = []string{"SHA-256", "SHA-256-sess", "MD5", "MD5-sess"}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+// DigestAuthenticator implements the HTTP Digest access authentication scheme (RFC 7616).
+// The first request is sent without credentials; the server challenge is answered transparently
+// and cached, so subsequent requests are authenticated without an additional round trip.
+// The MD5 and SHA-256 algorithms (and their session variants) are supported, with or without `qop=auth`.
+type DigestAuthenticator struct {
+	username string
+	password string
+	cnonce   func() string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+// DigestAuth creates a DigestAuthenticator for the given credentials.
+func DigestAuth(username, password string) *DigestAuthenticator {
+	return &DigestAuthenticator{
+		username: username,
+		password: password,
+		cnonce:   randomCNonce,
+	}
+}
+
+func (a *DigestAuthenticator) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.challenge == nil {
+		return nil
+	}
+	a.nc++
+	req.Header.Set("Authorization", a.authorization(req, a.challenge, a.nc))
+	return nil
+}
+
+// HandleChallenge caches the Digest challenge of the 401 response. A request is not retried when
+// the server rejected credentials computed for the same, non-stale nonce that the request was sent with.
+func (a *DigestAuthenticator) HandleChallenge(resp *http.Response) (bool, error) {
+	var challenge *digestChallenge
+	for _, v := range resp.Header.Values("WWW-Authenticate") {
+		c, ok := parseDigestChallenge(v)
+		if ok && (challenge == nil || digestPreference(c.algorithm) < digestPreference(challenge.algorithm)) {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return false, nil
+	}
+
+	// The nonce is compared with the one sent by the rejected request, since concurrent requests
+	// may have already cached the new challenge.
+	if sent := sentDigestNonce(resp.Request); sent != "" && !challenge.stale && sent == challenge.nonce {
+		return false, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.challenge == nil || a.challenge.nonce != challenge.nonce {
+		a.nc = 0
+	}
+	a.challenge = challenge
+	return true, nil
+}
+
+// sentDigestNonce returns the nonce of the Digest credentials sent with the request, if any.
+func sentDigestNonce(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	scheme, params, _ := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return ""
+	}
+	return parseAuthParams(params)["nonce"]
+}
+
+func (a *DigestAuthenticator) authorization(req *http.Request, c *digestChallenge, nc uint32) string {
+	h := digestHash(c.algorithm)
+	uri := req.URL.RequestURI()
+	cnonce := a.cnonce()
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(a.username + ":" + c.realm + ":" + a.password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	var response string
+	if c.qop != "" {
+		response = h(strings.Join([]string{ha1, c.nonce, ncValue, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", a.username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + c.algorithm,
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+ncValue, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	return "Digest " + strings.Join(params, ", ")
+}
+
+// parseDigestChallenge parses a WWW-Authenticate header value.
+// Challenges with unsupported algorithms, or offering only unsupported qop values, are ignored.
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	if !strings.EqualFold(scheme, "Digest") {
+		return nil, false
+	}
+	params := parseAuthParams(rest)
+	c := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+		stale:     strings.EqualFold(params["stale"], "true"),
+	}
+	if c.algorithm == "" {
+		c.algorithm = "MD5"
+	}
+	if c.nonce == "" || digestPreference(c.algorithm) == len(digestAlgorithms) {
+		return nil, false
+	}
+	if qop, ok := params["qop"]; ok {
+		for _, v := range strings.Split(qop, ",") {
+			if strings.TrimSpace(v) == "auth" {
+				c.qop = "auth"
+			}
+		}
+		if c.qop == "" {
+			return nil, false
+		}
+	}
+	return c, true
+}
+
+// parseAuthParams parses comma-separated `name=value` pairs, where values are optionally quoted.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+func digestPreference(algorithm string) int {
+	for i, a := range digestAlgorithms {
+		if strings.EqualFold(a, algorithm) {
+			return i
+		}
+	}
+	return len(digestAlgorithms)
+}
+
+func digestHash(algorithm string) func(string) string {
+	newHash := md5.New
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		newHash = sha256.New
+	}
+	return func(s string) string {
+		h := newHash()
+		h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+}
+
+func randomCNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
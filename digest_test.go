@@ -0,0 +1,174 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// digestServer is a minimal RFC 7616 server that accepts a single user.
+type digestServer struct {
+	algorithm string
+	qop       string
+	password  string
+
+	mu           sync.Mutex
+	nonce        int
+	challenges   int
+	nonceCounts  []string
+	staleOnCount int
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+	nonce := fmt.Sprintf("nonce-%d", s.nonce)
+	if params["nonce"] == nonce && params["response"] == s.expectedResponse(r, params) {
+		s.nonceCounts = append(s.nonceCounts, params["nc"])
+		if s.staleOnCount == 0 || len(s.nonceCounts) != s.staleOnCount {
+			_, _ = w.Write([]byte("OK"))
+			return
+		}
+		s.nonce++
+		nonce = fmt.Sprintf("nonce-%d", s.nonce)
+	}
+	s.challenges++
+	challenge := fmt.Sprintf(`Digest realm="appliance", nonce="%s", opaque="0p4qu3", algorithm=%s`, nonce, s.algorithm)
+	if s.qop != "" {
+		challenge += fmt.Sprintf(`, qop="%s"`, s.qop)
+	}
+	if params["nonce"] != "" && params["nonce"] != nonce {
+		challenge += ", stale=true"
+	}
+	w.Header().Add("WWW-Authenticate", `Basic realm="appliance"`)
+	w.Header().Add("WWW-Authenticate", challenge)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func (s *digestServer) expectedResponse(r *http.Request, params map[string]string) string {
+	h := digestHash(s.algorithm)
+	ha1 := h(params["username"] + ":appliance:" + s.password)
+	if strings.HasSuffix(s.algorithm, "-sess") {
+		ha1 = h(ha1 + ":" + params["nonce"] + ":" + params["cnonce"])
+	}
+	ha2 := h(r.Method + ":" + r.URL.RequestURI())
+	if params["uri"] != r.URL.RequestURI() || params["opaque"] != "0p4qu3" {
+		return ""
+	}
+	if s.qop == "" {
+		return h(ha1 + ":" + params["nonce"] + ":" + ha2)
+	}
+	return h(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+}
+
+func TestDigestAuthenticator(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		qop       string
+	}{
+		{algorithm: "MD5", qop: "auth,auth-int"},
+		{algorithm: "MD5"},
+		{algorithm: "MD5-sess", qop: "auth"},
+		{algorithm: "SHA-256", qop: "auth"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s qop=%s", tt.algorithm, tt.qop), func(t *testing.T) {
+			server := &digestServer{algorithm: tt.algorithm, qop: tt.qop, password: "s3cr3t"}
+			ts := httptest.NewServer(server)
+			defer ts.Close()
+			c, err := New().WithAuthenticator(DigestAuth("admin", "s3cr3t")).WithBaseURL(ts.URL)
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				resp, err := c.Post(context.Background(), "/api/config?section=network", strings.NewReader("{}"))
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+
+			// Only the first request is challenged, the nonce is reused afterward.
+			assert.Equal(t, 1, server.challenges)
+			if tt.qop != "" {
+				assert.Equal(t, []string{"00000001", "00000002", "00000003"}, server.nonceCounts)
+			}
+		})
+	}
+}
+
+func TestDigestAuthenticator_StaleNonce(t *testing.T) {
+	server := &digestServer{algorithm: "SHA-256", qop: "auth", password: "s3cr3t", staleOnCount: 2}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	c := New().WithAuthenticator(DigestAuth("admin", "s3cr3t"))
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(context.Background(), ts.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	assert.Equal(t, 2, server.challenges)
+	// The nonce count is reset for the new nonce.
+	assert.Equal(t, []string{"00000001", "00000002", "00000001", "00000002"}, server.nonceCounts)
+}
+
+func TestDigestAuthenticator_InvalidCredentials(t *testing.T) {
+	server := &digestServer{algorithm: "MD5", qop: "auth", password: "s3cr3t"}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	c := New().WithAuthenticator(DigestAuth("admin", "wrong"))
+
+	resp, err := c.Get(context.Background(), ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 2, server.challenges)
+
+	// The cached challenge is not retried with the same nonce.
+	resp, err = c.Get(context.Background(), ts.URL)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 3, server.challenges)
+}
+
+func TestDigestAuthenticator_ConcurrentNonceRotation(t *testing.T) {
+	a := DigestAuth("admin", "s3cr3t")
+	challenge := func(req *http.Request, nonce string) *http.Response {
+		resp := &http.Response{StatusCode: http.StatusUnauthorized, Header: http.Header{}, Request: req}
+		resp.Header.Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="appliance", nonce="%s", qop="auth"`, nonce))
+		return resp
+	}
+	retry, err := a.HandleChallenge(challenge(httptest.NewRequest(http.MethodGet, "/", nil), "nonce-0"))
+	require.NoError(t, err)
+	require.True(t, retry)
+
+	// Two requests are sent concurrently with the first nonce.
+	first := httptest.NewRequest(http.MethodGet, "/", nil)
+	second := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, a.Authenticate(first))
+	require.NoError(t, a.Authenticate(second))
+
+	// The server rotates the nonce without marking it as stale. The rejection of the first request
+	// caches the new nonce, which must not prevent the second request from being retried.
+	retry, err = a.HandleChallenge(challenge(first, "nonce-1"))
+	require.NoError(t, err)
+	assert.True(t, retry)
+	retry, err = a.HandleChallenge(challenge(second, "nonce-1"))
+	require.NoError(t, err)
+	assert.True(t, retry)
+
+	// Credentials computed for the new nonce are not retried when rejected again.
+	third := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, a.Authenticate(third))
+	assert.Contains(t, third.Header.Get("Authorization"), `nc=00000001`)
+	retry, err = a.HandleChallenge(challenge(third, "nonce-1"))
+	require.NoError(t, err)
+	assert.False(t, retry)
+}
@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -8,6 +9,21 @@ import (
 type ErrorTag string
 type ErrorTagCollection []ErrorTag
 
+const (
+	// ErrorTagAuthentication is used for errors that occurred while applying request credentials.
+	ErrorTagAuthentication ErrorTag = "authentication"
+	// ErrorTagSigning is used for errors that occurred while signing the request.
+	ErrorTagSigning ErrorTag = "signing"
+	// ErrorTagRateLimited is used for requests rejected by a client-side RateLimiter.
+	ErrorTagRateLimited ErrorTag = "rate_limited"
+	// ErrorTagCircuitOpen is used for requests rejected by an open CircuitBreaker.
+	ErrorTagCircuitOpen ErrorTag = "circuit_open"
+	// ErrorTagConcurrencyLimited is used for requests rejected by a full ConcurrencyLimiter queue.
+	ErrorTagConcurrencyLimited ErrorTag = "concurrency_limited"
+	// ErrorTagHook is used for requests aborted by a RequestHook.
+	ErrorTagHook ErrorTag = "hook"
+)
+
 func (c ErrorTagCollection) String(delimiter string) string {
 	r := make([]string, len(c))
 	for i, t := range c {
@@ -21,6 +37,10 @@ type BaseError struct {
 	tags        ErrorTagCollection
 }
 
+func newBaseError(err error, tags ...ErrorTag) *BaseError {
+	return &BaseError{originalErr: err, tags: tags}
+}
+
 func (e *BaseError) Error() string {
 	if len(e.tags) == 0 {
 		return fmt.Sprintf("[httpclient] %s", e.originalErr.Error())
@@ -31,3 +51,24 @@ func (e *BaseError) Error() string {
 func (e *BaseError) Unwrap() error {
 	return e.originalErr
 }
+
+// Tags returns the tags of the error.
+func (e *BaseError) Tags() ErrorTagCollection {
+	return append(ErrorTagCollection(nil), e.tags...)
+}
+
+// HasTag reports whether the error is tagged with the given tag.
+func (e *BaseError) HasTag(tag ErrorTag) bool {
+	for _, t := range e.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrorTag reports whether the first BaseError found in the chain of err is tagged with the given tag.
+func HasErrorTag(err error, tag ErrorTag) bool {
+	var baseErr *BaseError
+	return errors.As(err, &baseErr) && baseErr.HasTag(tag)
+}
@@ -5,11 +5,11 @@ go 1.23.0
 require (
 	github.com/jarcoal/httpmock v1.3.1
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/sync v0.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sync v0.8.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
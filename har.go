@@ -0,0 +1,444 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultHARMaxBodySize is the default number of bytes of each request and response body kept by a HARRecorder.
+const DefaultHARMaxBodySize = 1 << 20
+
+// HAR is an HTTP Archive in the HAR 1.2 format, which can be imported in browser developer tools.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single request attempt. Attempts that received no response have a zero status
+// and the error message in the custom `_error` field.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARContent is the response body. Size is the number of bytes read before the body was closed,
+// and Text is base64-encoded when the body is not valid UTF-8.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings are the durations of the request phases in milliseconds; -1 means that the phase did not take place.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder records every request attempt that passes through its Middleware as a HAREntry.
+// An entry is complete, and recorded, once the response body has been read completely or closed.
+// Up to DefaultHARMaxBodySize bytes of each body are kept, and the values of sensitive headers, cookies,
+// query parameters and URL passwords are replaced with RedactedValue. Only replayable request bodies are recorded.
+// The recorder is safe for concurrent use.
+type HARRecorder struct {
+	maxBodySize int
+	redactor    *redactor
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries []HAREntry
+}
+
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{
+		maxBodySize: DefaultHARMaxBodySize,
+		redactor:    newRedactor(),
+		now:         time.Now,
+	}
+}
+
+// WithMaxBodySize sets the number of bytes of each body that are kept. Zero disables body recording.
+func (r *HARRecorder) WithMaxBodySize(maxSize int) *HARRecorder {
+	r.maxBodySize = maxSize
+	return r
+}
+
+// WithRedactedHeaders adds to the headers whose values are redacted.
+func (r *HARRecorder) WithRedactedHeaders(names ...string) *HARRecorder {
+	r.redactor.addHeaders(names...)
+	return r
+}
+
+// WithRedactedQueryParameters adds to the query parameters whose values are redacted.
+func (r *HARRecorder) WithRedactedQueryParameters(names ...string) *HARRecorder {
+	r.redactor.addQueryParameters(names...)
+	return r
+}
+
+// Middleware returns the Middleware that records requests.
+func (r *HARRecorder) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			trace := &timingTrace{now: r.now, start: r.now()}
+			entry := &HAREntry{StartedDateTime: trace.start, Request: r.request(req)}
+			resp, err := next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace())))
+			if err != nil {
+				entry.Response = HARResponse{
+					Cookies:     []HARCookie{},
+					Headers:     []HARNameValue{},
+					HeadersSize: -1,
+					BodySize:    -1,
+				}
+				entry.Error = err.Error()
+				r.add(entry, trace)
+				return resp, err
+			}
+			entry.Response = r.response(resp)
+			if resp.Body == nil || resp.Body == http.NoBody {
+				r.add(entry, trace)
+				return resp, nil
+			}
+			resp.Body = &harBody{ReadCloser: resp.Body, recorder: r, entry: entry, trace: trace}
+			return resp, nil
+		})
+	}
+}
+
+// Entries returns the recorded entries in the order they started.
+func (r *HARRecorder) Entries() []HAREntry {
+	r.mu.Lock()
+	entries := append([]HAREntry(nil), r.entries...)
+	r.mu.Unlock()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	return entries
+}
+
+// HAR returns the archive of the recorded entries.
+func (r *HARRecorder) HAR() HAR {
+	entries := r.Entries()
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "go-httpclient"},
+		Entries: entries,
+	}}
+}
+
+// Reset discards the recorded entries.
+func (r *HARRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// WriteTo writes the archive of the recorded entries as JSON.
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// WriteFile writes the archive of the recorded entries to the named file, creating or truncating it.
+func (r *HARRecorder) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (r *HARRecorder) add(entry *HAREntry, trace *timingTrace) {
+	entry.Timings, entry.Time = harTimings(trace)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *entry)
+}
+
+func (r *HARRecorder) request(req *http.Request) HARRequest {
+	u := r.redactor.url(req.URL)
+	h := HARRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     r.cookies(req.Cookies(), "Cookie"),
+		Headers:     r.headers(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if h.HTTPVersion == "" {
+		h.HTTPVersion = "HTTP/1.1"
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			h.QueryString = append(h.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		h.BodySize = 0
+		return h
+	}
+	if r.maxBodySize > 0 && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			defer body.Close()
+			b, err := io.ReadAll(io.LimitReader(body, int64(r.maxBodySize)+1))
+			if err == nil {
+				text, _, comment := r.bodyText(b)
+				h.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: text, Comment: comment}
+			}
+		}
+	}
+	return h
+}
+
+func (r *HARRecorder) response(resp *http.Response) HARResponse {
+	statusText := strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)+" ")
+	if statusText == "" || statusText == resp.Status {
+		statusText = http.StatusText(resp.StatusCode)
+	}
+	h := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  statusText,
+		HTTPVersion: resp.Proto,
+		Cookies:     r.cookies(resp.Cookies(), "Set-Cookie"),
+		Headers:     r.headers(resp.Header),
+		Content:     HARContent{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	if h.HTTPVersion == "" {
+		h.HTTPVersion = "HTTP/1.1"
+	}
+	return h
+}
+
+func (r *HARRecorder) headers(header http.Header) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			values = append(values, HARNameValue{Name: name, Value: r.redactor.header(name, value)})
+		}
+	}
+	return values
+}
+
+// cookies converts the cookies sent or received in the given header, redacting their values along with the header.
+func (r *HARRecorder) cookies(cookies []*http.Cookie, header string) []HARCookie {
+	values := make([]HARCookie, 0, len(cookies))
+	for _, c := range cookies {
+		hc := HARCookie{
+			Name:     c.Name,
+			Value:    r.redactor.header(header, c.Value),
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			hc.Expires = &expires
+		}
+		values = append(values, hc)
+	}
+	return values
+}
+
+// bodyText returns the recorded text of a body, which holds up to one byte more than the maximum size
+// in order to detect truncation, along with its encoding and a comment if it was truncated.
+func (r *HARRecorder) bodyText(b []byte) (text, encoding, comment string) {
+	if len(b) > r.maxBodySize {
+		b = b[:r.maxBodySize]
+		comment = "truncated to " + strconv.Itoa(r.maxBodySize) + " bytes"
+	}
+	if utf8.Valid(b) {
+		return string(b), "", comment
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64", comment
+}
+
+func harTimings(t *timingTrace) (HARTimings, float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.bodyDone
+	if end.IsZero() {
+		end = t.now()
+	}
+	phase := func(start, end time.Time) float64 {
+		if start.IsZero() || end.IsZero() {
+			return -1
+		}
+		return milliseconds(between(start, end))
+	}
+	connectDone := t.connectDone
+	if !t.tlsDone.IsZero() {
+		connectDone = t.tlsDone
+	}
+	timings := HARTimings{
+		Blocked: -1,
+		DNS:     phase(t.dnsStart, t.dnsDone),
+		Connect: phase(t.connectStart, connectDone),
+		SSL:     phase(t.tlsStart, t.tlsDone),
+		Send:    max(phase(t.gotConn, t.wroteRequest), 0),
+		Wait:    max(phase(t.wroteRequest, t.firstByte), 0),
+		Receive: max(phase(t.firstByte, end), 0),
+	}
+	if !t.gotConn.IsZero() {
+		firstPhase := t.gotConn
+		for _, start := range []time.Time{t.connectStart, t.dnsStart} {
+			if !start.IsZero() && start.Before(firstPhase) {
+				firstPhase = start
+			}
+		}
+		timings.Blocked = phase(t.start, firstPhase)
+	}
+	return timings, milliseconds(between(t.start, end))
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// harBody records the prefix of the response body and completes the entry once the body is read completely or closed.
+type harBody struct {
+	io.ReadCloser
+	recorder *HARRecorder
+	entry    *HAREntry
+	trace    *timingTrace
+	buf      bytes.Buffer
+	size     int64
+	once     sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if remaining := b.recorder.maxBodySize + 1 - b.buf.Len(); remaining > 0 {
+		b.buf.Write(p[:min(n, remaining)])
+	}
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *harBody) done() {
+	b.once.Do(func() {
+		b.trace.mu.Lock()
+		b.trace.bodyDone = b.trace.now()
+		b.trace.mu.Unlock()
+
+		content := &b.entry.Response.Content
+		content.Size = b.size
+		b.entry.Response.BodySize = b.size
+		if b.recorder.maxBodySize > 0 {
+			content.Text, content.Encoding, content.Comment = b.recorder.bodyText(b.buf.Bytes())
+		}
+		b.recorder.add(b.entry, b.trace)
+	})
+}
@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithHARRecorder(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", HttpOnly: true})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":42}`))
+	}))
+	defer srv.Close()
+	recorder := NewHARRecorder()
+	c := NewWithTransport(srv.Client().Transport).WithHARRecorder(recorder)
+
+	resp, err := c.Post(context.Background(), srv.URL+"/items?access_token=abc&b=2&a=1", strings.NewReader(`{"name":"a"}`),
+		WithHeaders(map[string]string{"Content-Type": "application/json", "X-Api-Key": "key"}))
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Entries(), "the entry is recorded once the body is consumed")
+	assert.Equal(t, `{"id":42}`, string(MustInterceptResponseBody(resp)))
+
+	entries := recorder.Entries()
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, http.MethodPost, entry.Request.Method)
+	assert.Equal(t, srv.URL+"/items?a=1&access_token=REDACTED&b=2", entry.Request.URL)
+	assert.Equal(t, []HARNameValue{
+		{Name: "a", Value: "1"},
+		{Name: "access_token", Value: "REDACTED"},
+		{Name: "b", Value: "2"},
+	}, entry.Request.QueryString)
+	assert.Contains(t, entry.Request.Headers, HARNameValue{Name: "X-Api-Key", Value: "REDACTED"})
+	assert.Equal(t, &HARPostData{MimeType: "application/json", Text: `{"name":"a"}`}, entry.Request.PostData)
+	assert.Equal(t, int64(12), entry.Request.BodySize)
+
+	assert.Equal(t, http.StatusCreated, entry.Response.Status)
+	assert.Equal(t, "Created", entry.Response.StatusText)
+	assert.Equal(t, "HTTP/1.1", entry.Response.HTTPVersion)
+	assert.Equal(t, HARContent{Size: 9, MimeType: "application/json", Text: `{"id":42}`}, entry.Response.Content)
+	assert.Equal(t, []HARCookie{{Name: "session", Value: "REDACTED", HTTPOnly: true}}, entry.Response.Cookies)
+	assert.Contains(t, entry.Response.Headers, HARNameValue{Name: "Set-Cookie", Value: "REDACTED"})
+
+	assert.Positive(t, entry.Timings.Connect)
+	assert.Positive(t, entry.Timings.SSL)
+	assert.GreaterOrEqual(t, entry.Timings.Connect, entry.Timings.SSL)
+	assert.GreaterOrEqual(t, entry.Time, entry.Timings.Connect+entry.Timings.Wait)
+
+	path := filepath.Join(t.TempDir(), "session.har")
+	require.NoError(t, recorder.WriteFile(path))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var har map[string]any
+	require.NoError(t, json.Unmarshal(b, &har))
+	log := har["log"].(map[string]any)
+	assert.Equal(t, "1.2", log["version"])
+	assert.Len(t, log["entries"], 1)
+}
+
+func TestHARRecorder(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/fail" {
+			return nil, errors.New("connection refused")
+		}
+		return httpmock.NewBytesResponse(http.StatusOK, append([]byte{0xff}, bytes.Repeat([]byte("a"), 9)...)), nil
+	})
+	recorder := NewHARRecorder().WithMaxBodySize(4)
+	c := NewWithTransport(mt).WithHARRecorder(recorder)
+
+	_, err := c.Get(context.Background(), "https://api.example.com/fail")
+	require.Error(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "https://api.example.com/blob")
+			if assert.NoError(t, err) {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := recorder.Entries()
+	require.Len(t, entries, 11)
+	assert.Equal(t, 0, entries[0].Response.Status)
+	assert.Contains(t, entries[0].Error, "connection refused")
+	assert.Equal(t, HARContent{Size: 10, Text: "/2FhYQ==", Encoding: "base64", Comment: "truncated to 4 bytes"},
+		entries[1].Response.Content)
+	assert.Equal(t, -1.0, entries[1].Timings.DNS)
+
+	recorder.Reset()
+	var buf bytes.Buffer
+	_, err = recorder.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), `"entries": []`)
+}
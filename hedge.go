@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeMinSamples is the number of latency samples required before a percentile-based hedging delay is used.
+const hedgeMinSamples = 20
+
+// hedgeWindowSize is the number of most recent latency samples used for percentile-based hedging delays.
+const hedgeWindowSize = 256
+
+// HedgePolicy configures hedged requests: if no response has been received after the hedging delay,
+// a second copy of the request is sent and whichever answers first is used.
+// The other request is cancelled and its response body, if any, is closed.
+// A zero HedgePolicy disables hedging.
+type HedgePolicy struct {
+	// Delay is the time to wait for a response before sending the second request.
+	Delay time.Duration
+	// Percentile, when set, uses the given percentile of the observed latency as the delay,
+	// e.g. 0.95 for the 95th percentile. Delay is used until enough latency samples have been observed;
+	// if Delay is zero, requests are not hedged until then.
+	Percentile float64
+	// AllowUnsafeMethods enables hedging for all methods, instead of only the safe methods (GET, HEAD, OPTIONS, TRACE).
+	// Requests with a body are hedged only if the body can be replayed.
+	AllowUnsafeMethods bool
+}
+
+func (p HedgePolicy) enabled() bool {
+	return p.Delay > 0 || p.Percentile > 0
+}
+
+// WithHedging overrides the Client HedgePolicy for the request. Pass a zero HedgePolicy in order to disable hedging.
+func WithHedging(policy HedgePolicy) RequestParameter {
+	return func(opts *RequestParameters) {
+		opts.hedging = &policy
+	}
+}
+
+type hedger struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+func (h *hedger) record(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeWindowSize {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeWindowSize
+}
+
+// delay returns the hedging delay of the policy. False is returned if the request must not be hedged,
+// because the percentile-based delay has too few latency samples and there is no fallback Delay.
+func (h *hedger) delay(policy HedgePolicy) (time.Duration, bool) {
+	if policy.Percentile <= 0 {
+		return policy.Delay, true
+	}
+	h.mu.Lock()
+	if len(h.latencies) < hedgeMinSamples {
+		h.mu.Unlock()
+		return policy.Delay, policy.Delay > 0
+	}
+	sorted := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(math.Min(policy.Percentile, 1)*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)], true
+}
+
+func hedgeable(req *http.Request, policy HedgePolicy) bool {
+	if !policy.enabled() {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return policy.AllowUnsafeMethods
+	}
+}
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+	start time.Time
+}
+
+func (h *hedger) roundTrip(next http.RoundTripper, req *http.Request, policy HedgePolicy) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(body func() (io.ReadCloser, error)) {
+		index := len(cancels)
+		ctx, cancel := context.WithCancel(context.WithValue(req.Context(), attemptKey{}, Attempt(req)+index))
+		cancels = append(cancels, cancel)
+		r := req.Clone(ctx)
+		start := time.Now()
+		go func() {
+			var err error
+			if body != nil {
+				if r.Body, err = body(); err != nil {
+					results <- hedgeResult{index: index, err: err, start: start}
+					return
+				}
+			}
+			resp, err := next.RoundTrip(r)
+			results <- hedgeResult{index: index, resp: resp, err: err, start: start}
+		}()
+	}
+
+	send(nil)
+	// The latency of requests that are not hedged is still recorded, so that percentile-based delays warm up.
+	var hedge <-chan time.Time
+	if delay, ok := h.delay(policy); ok {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge = timer.C
+	}
+	pending := 1
+	for {
+		select {
+		case <-hedge:
+			pending++
+			send(req.GetBody)
+		case res := <-results:
+			pending--
+			if res.err == nil || pending == 0 {
+				for i, cancel := range cancels {
+					if i != res.index || res.err != nil {
+						cancel()
+					}
+				}
+				if res.err == nil {
+					h.record(time.Since(res.start))
+					res.resp.Body = &cancelOnCloseBody{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+				}
+				go discardHedgeResults(results, pending)
+				return res.resp, res.err
+			}
+			// The request failed while another copy is still in flight.
+			cancels[res.index]()
+		}
+	}
+}
+
+// discardHedgeResults releases the resources of the requests that lost the race.
+func discardHedgeResults(results <-chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		res := <-results
+		if res.resp != nil {
+			res.resp.Body.Close()
+		}
+	}
+}
+
+// cancelOnCloseBody releases the context of the winning request once its body is closed.
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
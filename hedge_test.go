@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowFirstCallTransport delays the first call until its context is cancelled.
+func slowFirstCallTransport(calls *atomic.Int32, cancelled chan<- struct{}) *httpmock.MockTransport {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		n := calls.Add(1)
+		if n == 1 {
+			<-req.Context().Done()
+			close(cancelled)
+			return nil, req.Context().Err()
+		}
+		return httpmock.NewStringResponse(http.StatusOK, "hedged"), nil
+	})
+	return mt
+}
+
+func TestClient_WithHedging(t *testing.T) {
+	calls := &atomic.Int32{}
+	cancelled := make(chan struct{})
+	c := NewWithTransport(slowFirstCallTransport(calls, cancelled)).
+		WithHedging(HedgePolicy{Delay: 10 * time.Millisecond})
+
+	resp, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+
+	assert.Equal(t, "hedged", string(MustInterceptResponseBody(resp)))
+	assert.Equal(t, int32(2), calls.Load())
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow request was not cancelled")
+	}
+}
+
+func TestClient_WithHedging_UnsafeMethods(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	calls := &atomic.Int32{}
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		time.Sleep(30 * time.Millisecond)
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		return httpmock.NewStringResponse(http.StatusOK, string(body)), nil
+	})
+	c := NewWithTransport(mt).WithHedging(HedgePolicy{Delay: time.Millisecond})
+
+	resp, err := c.Post(context.Background(), "https://api.example.com/items", strings.NewReader("payload"))
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(MustInterceptResponseBody(resp)))
+	assert.Equal(t, int32(1), calls.Load())
+
+	resp, err = c.Post(context.Background(), "https://api.example.com/items", strings.NewReader("payload"),
+		WithHedging(HedgePolicy{Delay: time.Millisecond, AllowUnsafeMethods: true}))
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(MustInterceptResponseBody(resp)))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestClient_WithHedging_Disabled(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	calls := &atomic.Int32{}
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+	})
+	c := NewWithTransport(mt).WithHedging(HedgePolicy{Delay: time.Millisecond})
+
+	_, err := c.Get(context.Background(), "https://api.example.com/items", WithHedging(HedgePolicy{}))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestHedger_Delay(t *testing.T) {
+	h := &hedger{}
+	policy := HedgePolicy{Delay: time.Second, Percentile: 0.9}
+	for i := 1; i < hedgeMinSamples; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	delay, ok := h.delay(policy)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+	_, ok = h.delay(HedgePolicy{Percentile: 0.9})
+	assert.False(t, ok)
+
+	h.record(hedgeMinSamples * time.Millisecond)
+	delay, ok = h.delay(HedgePolicy{Percentile: 0.9})
+	assert.True(t, ok)
+	assert.Equal(t, 18*time.Millisecond, delay)
+
+	for i := 0; i < hedgeWindowSize; i++ {
+		h.record(time.Millisecond)
+	}
+	delay, _ = h.delay(policy)
+	assert.Equal(t, time.Millisecond, delay)
+}
+
+func TestClient_WithHedging_PercentileWarmUp(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	calls := &atomic.Int32{}
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond)
+		return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+	})
+	c := NewWithTransport(mt).WithHedging(HedgePolicy{Percentile: 0.95})
+
+	// Requests are not hedged until enough latency samples have been observed.
+	for i := 0; i < hedgeMinSamples; i++ {
+		resp, err := c.Get(context.Background(), "https://api.example.com/items")
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, int32(i+1), calls.Load())
+	}
+	_, ok := c.hedger.delay(c.hedging)
+	assert.True(t, ok)
+}
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HookScope determines how often a hook registered on the Client is invoked.
+// PerCall request hooks are invoked before the PerAttempt ones,
+// while PerCall response and error hooks are invoked after them.
+type HookScope int
+
+const (
+	// PerCall hooks are invoked once per logical call, e.g. once per Client.Get,
+	// regardless of how many attempts the call required.
+	PerCall HookScope = iota
+	// PerAttempt hooks are invoked for every attempt of a call, including hedged attempts.
+	// Use Attempt in order to tell the attempts apart.
+	PerAttempt
+)
+
+func (s HookScope) String() string {
+	switch s {
+	case PerCall:
+		return "call"
+	case PerAttempt:
+		return "attempt"
+	default:
+		return fmt.Sprintf("HookScope(%d)", int(s))
+	}
+}
+
+// RequestHook is invoked before a request is sent and may modify it, e.g. in order to set a request ID header.
+// Returning an error aborts the call; the error is tagged with ErrorTagHook.
+type RequestHook func(req *http.Request) error
+
+// ResponseHook is invoked after a response has been received, regardless of its status code.
+// The response body must not be consumed.
+type ResponseHook func(req *http.Request, resp *http.Response)
+
+// ErrorHook is invoked when no response could be received, including when a RequestHook aborts the call.
+type ErrorHook func(req *http.Request, err error)
+
+// hooks are invoked in the order of registration.
+type hooks struct {
+	request  []RequestHook
+	response []ResponseHook
+	error    []ErrorHook
+}
+
+func (h *hooks) empty() bool {
+	return len(h.request) == 0 && len(h.response) == 0 && len(h.error) == 0
+}
+
+func (h *hooks) beforeSend(req *http.Request) error {
+	for _, hook := range h.request {
+		if err := hook(req); err != nil {
+			return newBaseError(fmt.Errorf("request hook failed: %w", err), ErrorTagHook)
+		}
+	}
+	return nil
+}
+
+func (h *hooks) received(req *http.Request, resp *http.Response) {
+	for _, hook := range h.response {
+		hook(req, resp)
+	}
+}
+
+func (h *hooks) failed(req *http.Request, err error) {
+	for _, hook := range h.error {
+		hook(req, err)
+	}
+}
+
+// hookTransport invokes the PerAttempt hooks around every attempt.
+type hookTransport struct {
+	hooks *hooks
+	next  http.RoundTripper
+}
+
+func (t *hookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.hooks.request) > 0 {
+		// A RoundTripper must not modify the request it was given.
+		req = req.Clone(req.Context())
+		if err := t.hooks.beforeSend(req); err != nil {
+			t.hooks.failed(req, err)
+			return nil, err
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.hooks.failed(req, err)
+		return nil, err
+	}
+	t.hooks.received(req, resp)
+	return resp, nil
+}
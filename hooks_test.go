@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Hooks(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	var received http.Header
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		received = req.Header.Clone()
+		return httpmock.NewStringResponse(http.StatusServiceUnavailable, ""), nil
+	})
+	var events []string
+	record := func(event string) { events = append(events, event) }
+	middleware := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			record("middleware " + req.Header.Get("X-Request-Id"))
+			return next.RoundTrip(req)
+		})
+	}
+	c := NewWithTransport(mt).
+		WithMiddleware(middleware).
+		OnRequest(PerAttempt, func(req *http.Request) error {
+			record("attempt request")
+			req.Header.Set("X-Attempt", "0")
+			return nil
+		}).
+		OnRequest(PerCall, func(req *http.Request) error {
+			record("call request 1")
+			req.Header.Set("X-Request-Id", "abc")
+			return nil
+		}).
+		OnRequest(PerCall, func(req *http.Request) error {
+			record("call request 2")
+			return nil
+		}).
+		OnResponse(PerCall, func(_ *http.Request, resp *http.Response) {
+			record("call response " + resp.Status)
+		}).
+		OnResponse(PerAttempt, func(req *http.Request, resp *http.Response) {
+			record("attempt response " + req.Header.Get("X-Attempt"))
+		}).
+		OnError(PerCall, func(*http.Request, error) {
+			record("call error")
+		})
+
+	resp, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	assert.Equal(t, []string{
+		"call request 1",
+		"call request 2",
+		"attempt request",
+		"middleware abc",
+		"attempt response 0",
+		"call response 503",
+	}, events)
+	assert.Equal(t, "abc", received.Get("X-Request-Id"))
+	assert.Equal(t, "0", received.Get("X-Attempt"))
+	// PerAttempt hooks modify a copy of the request.
+	assert.Empty(t, resp.Request.Header.Get("X-Attempt"))
+}
+
+func TestClient_Hooks_Errors(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	calls := &atomic.Int32{}
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return nil, errors.New("connection refused")
+	})
+	var callErrors, attemptErrors []error
+	abort := errors.New("missing tenant")
+	c := NewWithTransport(mt).
+		OnRequest(PerCall, func(req *http.Request) error {
+			if req.URL.Path == "/abort" {
+				return abort
+			}
+			return nil
+		}).
+		OnError(PerCall, func(_ *http.Request, err error) { callErrors = append(callErrors, err) }).
+		OnError(PerAttempt, func(_ *http.Request, err error) { attemptErrors = append(attemptErrors, err) })
+
+	_, err := c.Get(context.Background(), "https://api.example.com/abort")
+	require.ErrorIs(t, err, abort)
+	assert.True(t, HasErrorTag(err, ErrorTagHook))
+	assert.Zero(t, calls.Load())
+	require.Len(t, callErrors, 1)
+	assert.Empty(t, attemptErrors)
+
+	_, err = c.Get(context.Background(), "https://api.example.com/items")
+	require.Error(t, err)
+	require.Len(t, callErrors, 2)
+	assert.Equal(t, err, callErrors[1])
+	require.Len(t, attemptErrors, 1)
+	assert.ErrorContains(t, attemptErrors[0], "connection refused")
+}
+
+func TestClient_Hooks_Hedging(t *testing.T) {
+	calls := &atomic.Int32{}
+	cancelled := make(chan struct{})
+	var mu sync.Mutex
+	var attempts []int
+	callResponses := &atomic.Int32{}
+	c := NewWithTransport(slowFirstCallTransport(calls, cancelled)).
+		WithHedging(HedgePolicy{Delay: 10 * time.Millisecond}).
+		OnRequest(PerAttempt, func(req *http.Request) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts = append(attempts, Attempt(req))
+			return nil
+		}).
+		OnResponse(PerCall, func(*http.Request, *http.Response) { callResponses.Add(1) })
+
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []int{0, 1}, attempts)
+	assert.Equal(t, int32(1), callResponses.Load())
+}
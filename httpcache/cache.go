@@ -0,0 +1,375 @@
+// Package httpcache provides a private HTTP response cache for httpclient, following RFC 9111.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/georgepsarakis/go-httpclient"
+	"github.com/georgepsarakis/go-httpclient/internal/conditional"
+)
+
+// Cache is a private HTTP cache for GET requests. It honors the Cache-Control, Expires, Age and Vary
+// response headers, calculates heuristic freshness from Last-Modified, revalidates stale responses
+// with conditional requests and supports the `stale-while-revalidate` and `stale-if-error` extensions.
+// Every response passing through the Cache carries a Cache-Status header, see StatusFromResponse.
+//
+// Storage errors never fail a request; the Cache behaves as if the response was not stored.
+type Cache struct {
+	store Store
+	now   func() time.Time
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+	keyLocks     map[string]*keyLock
+}
+
+// keyLock serializes the updates of the variants stored under a key.
+type keyLock struct {
+	sync.Mutex
+	// users is guarded by the Cache lock.
+	users int
+}
+
+// New creates a Cache backed by the given Store.
+//
+//	cache := httpcache.New(httpcache.NewMemoryStore(64 << 20))
+//	client := httpclient.New().WithMiddleware(cache.Middleware())
+func New(store Store) *Cache {
+	return &Cache{
+		store:        store,
+		now:          time.Now,
+		revalidating: make(map[string]bool),
+		keyLocks:     make(map[string]*keyLock),
+	}
+}
+
+// entry is a stored response. RequestHeader holds the request header fields nominated by Vary.
+type entry struct {
+	StatusCode    int
+	Header        http.Header
+	Body          []byte
+	RequestHeader http.Header
+	RequestTime   time.Time
+	ResponseTime  time.Time
+}
+
+// date returns the value of the Date header, or the time the response was received if it is missing or invalid.
+func (e *entry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// matches reports whether the stored response can be selected for the request, see RFC 9111 Section 4.1.
+func (e *entry) matches(req *http.Request) bool {
+	for _, name := range conditional.VaryFields(e.Header) {
+		if normalizeHeader(e.RequestHeader, name) != normalizeHeader(req.Header, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *entry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// Middleware returns the httpclient.Middleware that serves responses from the Cache.
+func (c *Cache) Middleware() httpclient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return c.roundTrip(next, req)
+		})
+	}
+}
+
+func (c *Cache) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.forwardUncached(next, req, "fwd=method")
+	}
+	reqCC := requestDirectives(req.Header)
+	if reqCC.has("no-store") || conditional.IsRequest(req) || req.Header.Get("Range") != "" {
+		return c.forwardUncached(next, req, "fwd=request")
+	}
+
+	key := cacheKey(req.URL)
+	variants := c.load(key)
+	var stored *entry
+	for _, v := range variants {
+		if v.matches(req) {
+			stored = v
+			break
+		}
+	}
+	if stored == nil {
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		fwd := "fwd=uri-miss"
+		if len(variants) > 0 {
+			fwd = "fwd=vary-miss"
+		}
+		return c.fetch(next, req, nil, fwd)
+	}
+
+	now := c.now()
+	cc := parseCacheControl(stored.Header)
+	age := currentAge(stored, now)
+	ttl := freshnessLifetime(stored, cc) - age
+	if c.fresh(ttl, age, reqCC, cc) {
+		return c.serve(stored, req, age, "hit", ttlParam(ttl)), nil
+	}
+
+	staleness := -ttl
+	if !cc.has("must-revalidate") && !cc.has("no-cache") {
+		if maxStale, ok := reqCC["max-stale"]; ok && ttl < 0 {
+			if d, valid := reqCC.seconds("max-stale"); maxStale == "" || valid && staleness <= d {
+				return c.serve(stored, req, age, "hit", ttlParam(ttl)), nil
+			}
+		}
+		if d, ok := cc.seconds("stale-while-revalidate"); ok && ttl < 0 && staleness <= d && !reqCC.has("no-cache") {
+			c.revalidateInBackground(next, req, stored)
+			return c.serve(stored, req, age, "hit", ttlParam(ttl)), nil
+		}
+	}
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeout(req), nil
+	}
+
+	resp, err := c.fetch(next, req, stored, "fwd=stale")
+	if (err != nil || resp.StatusCode >= http.StatusInternalServerError) && c.staleIfError(staleness, reqCC, cc) {
+		if resp != nil {
+			drain(resp)
+		}
+		fwdStatus := "fwd-status=error"
+		if err == nil {
+			fwdStatus = "fwd-status=" + strconv.Itoa(resp.StatusCode)
+		}
+		return c.serve(stored, req, age, "fwd=stale", fwdStatus, ttlParam(ttl)), nil
+	}
+	return resp, err
+}
+
+// fresh reports whether a stored response can be served without contacting the origin.
+func (c *Cache) fresh(ttl, age time.Duration, reqCC, cc directives) bool {
+	if ttl <= 0 || cc.has("no-cache") || reqCC.has("no-cache") {
+		return false
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok && ttl < minFresh {
+		return false
+	}
+	return true
+}
+
+func (c *Cache) staleIfError(staleness time.Duration, reqCC, cc directives) bool {
+	if cc.has("must-revalidate") || cc.has("no-cache") {
+		return false
+	}
+	for _, d := range []directives{reqCC, cc} {
+		if limit, ok := d.seconds("stale-if-error"); ok && staleness <= limit {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cache) serve(e *entry, req *http.Request, age time.Duration, status ...string) *http.Response {
+	resp := e.response(req)
+	resp.Header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	setStatus(resp, status...)
+	return resp
+}
+
+// fetch forwards the request to the origin. If a stored response is given, the request is made conditional
+// using its validators and a 304 Not Modified response refreshes the stored response.
+func (c *Cache) fetch(next http.RoundTripper, req *http.Request, stored *entry, fwd string) (*http.Response, error) {
+	outReq := req
+	if stored != nil {
+		outReq = conditional.Request(req, stored.Header)
+	}
+	requestTime := c.now()
+	resp, err := next.RoundTrip(outReq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := c.now()
+
+	if stored != nil && resp.StatusCode == http.StatusNotModified && outReq != req {
+		drain(resp)
+		refreshed := *stored
+		// The Cache-Status of the 304 response describes the revalidation, not the stored response.
+		notModified := resp.Header.Clone()
+		notModified.Del(CacheStatusHeader)
+		refreshed.Header = conditional.UpdateHeader(stored.Header, notModified)
+		refreshed.RequestTime = requestTime
+		refreshed.ResponseTime = responseTime
+		c.save(cacheKey(req.URL), &refreshed)
+		served := c.serve(&refreshed, req, currentAge(&refreshed, responseTime), fwd, "fwd-status=304")
+		return served, nil
+	}
+
+	status := []string{fwd}
+	if fwd == "fwd=stale" {
+		status = append(status, "fwd-status="+strconv.Itoa(resp.StatusCode))
+	}
+	if storable(req, resp) {
+		body, err := httpclient.InterceptResponseBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		e := &entry{
+			StatusCode:    resp.StatusCode,
+			Header:        resp.Header.Clone(),
+			Body:          body,
+			RequestHeader: nominatedHeaders(req.Header, resp.Header),
+			RequestTime:   requestTime,
+			ResponseTime:  responseTime,
+		}
+		if c.save(cacheKey(req.URL), e) {
+			status = append(status, "stored")
+		}
+	}
+	setStatus(resp, status...)
+	return resp, nil
+}
+
+func (c *Cache) forwardUncached(next http.RoundTripper, req *http.Request, fwd string) (*http.Response, error) {
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if !isSafe(req.Method) && resp.StatusCode < http.StatusBadRequest {
+		c.invalidate(req, resp)
+	}
+	setStatus(resp, fwd)
+	return resp, nil
+}
+
+// revalidateInBackground refreshes a stale response without blocking the caller.
+// At most one background revalidation runs for each URL.
+func (c *Cache) revalidateInBackground(next http.RoundTripper, req *http.Request, stored *entry) {
+	key := cacheKey(req.URL)
+	c.mu.Lock()
+	if c.revalidating[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.revalidating[key] = true
+	c.mu.Unlock()
+
+	bgReq := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.revalidating, key)
+			c.mu.Unlock()
+		}()
+		resp, err := c.fetch(next, bgReq, stored, "fwd=stale")
+		if err == nil {
+			drain(resp)
+		}
+	}()
+}
+
+// invalidate removes the stored responses for the target URI of a successful unsafe request,
+// as well as the same-origin URIs in the Location and Content-Location headers, see RFC 9111 Section 4.4.
+func (c *Cache) invalidate(req *http.Request, resp *http.Response) {
+	c.delete(cacheKey(req.URL))
+	for _, h := range []string{"Location", "Content-Location"} {
+		v := resp.Header.Get(h)
+		if v == "" {
+			continue
+		}
+		u, err := req.URL.Parse(v)
+		if err != nil || u.Scheme != req.URL.Scheme || u.Host != req.URL.Host {
+			continue
+		}
+		c.delete(cacheKey(u))
+	}
+}
+
+func (c *Cache) delete(key string) {
+	defer c.lockKey(key)()
+	_ = c.store.Delete(key)
+}
+
+// load returns the stored variants of a URL. Unreadable values are treated as missing.
+func (c *Cache) load(key string) []*entry {
+	b, ok, err := c.store.Get(key)
+	if err != nil || !ok {
+		return nil
+	}
+	var variants []*entry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&variants); err != nil {
+		return nil
+	}
+	return variants
+}
+
+// save stores the entry, replacing any variant selected by the same request headers.
+// Concurrent saves of the same key are serialized, so that no variant is lost.
+func (c *Cache) save(key string, e *entry) bool {
+	defer c.lockKey(key)()
+	req := &http.Request{Header: e.RequestHeader}
+	variants := []*entry{e}
+	for _, v := range c.load(key) {
+		if !v.matches(req) || !sameVary(v.Header, e.Header) {
+			variants = append(variants, v)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(variants); err != nil {
+		return false
+	}
+	return c.store.Set(key, buf.Bytes()) == nil
+}
+
+// lockKey locks the key for updating and returns the function that unlocks it.
+func (c *Cache) lockKey(key string) func() {
+	c.mu.Lock()
+	l, ok := c.keyLocks[key]
+	if !ok {
+		l = &keyLock{}
+		c.keyLocks[key] = l
+	}
+	l.users++
+	c.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.mu.Lock()
+		l.users--
+		if l.users == 0 {
+			delete(c.keyLocks, key)
+		}
+		c.mu.Unlock()
+	}
+}
+
+func cacheKey(u *url.URL) string {
+	return http.MethodGet + " " + u.String()
+}
@@ -0,0 +1,417 @@
+package httpcache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+	"github.com/georgepsarakis/go-httpclient/httptesting"
+)
+
+const testURL = "https://api.example.com/resources/1"
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newCachingMock returns a mock client with a Cache. Every origin response is dated by the clock.
+func newCachingMock(t *testing.T, store Store, responder httpmock.Responder) (*httptesting.Mock, *fakeClock, *atomic.Int32) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	calls := &atomic.Int32{}
+	m := httptesting.NewMock(t)
+	m.Transport().RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		resp, err := responder(req)
+		if resp != nil {
+			resp.Header.Set("Date", clock.Now().Format(http.TimeFormat))
+		}
+		return resp, err
+	})
+	cache := New(store)
+	cache.now = clock.Now
+	m.Client.WithMiddleware(cache.Middleware())
+	return m, clock, calls
+}
+
+func stringResponder(status int, body string, headers map[string]string) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp := httpmock.NewStringResponse(status, body)
+		for k, v := range headers {
+			resp.Header.Set(k, v)
+		}
+		return resp, nil
+	}
+}
+
+func get(t *testing.T, c *httptesting.Mock, params ...httpclient.RequestParameter) (*http.Response, string) {
+	t.Helper()
+	resp, err := c.Get(context.Background(), testURL, params...)
+	require.NoError(t, err)
+	return resp, string(httpclient.MustInterceptResponseBody(resp))
+}
+
+func TestCache_Hit(t *testing.T) {
+	for name, store := range map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore(1 << 20) },
+		"disk": func(t *testing.T) Store {
+			s, err := NewDiskStore(t.TempDir())
+			require.NoError(t, err)
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, clock, calls := newCachingMock(t, store(t),
+				stringResponder(http.StatusOK, "cached", map[string]string{"Cache-Control": "max-age=60"}))
+
+			resp, body := get(t, c)
+			assert.Equal(t, "cached", body)
+			assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+			assert.Equal(t, "httpclient; fwd=uri-miss; stored", resp.Header.Get(CacheStatusHeader))
+
+			clock.Advance(10 * time.Second)
+			resp, body = get(t, c)
+			assert.Equal(t, "cached", body)
+			assert.Equal(t, StatusHit, StatusFromResponse(resp))
+			assert.Equal(t, "httpclient; hit; ttl=50", resp.Header.Get(CacheStatusHeader))
+			assert.Equal(t, "10", resp.Header.Get("Age"))
+			assert.Equal(t, int32(1), calls.Load())
+		})
+	}
+}
+
+func TestCache_NotStored(t *testing.T) {
+	c, _, calls := newCachingMock(t, NewMemoryStore(64),
+		stringResponder(http.StatusOK, strings.Repeat("x", 64), map[string]string{"Cache-Control": "max-age=60"}))
+
+	for i := 0; i < 2; i++ {
+		resp, _ := get(t, c)
+		assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+		assert.Equal(t, "httpclient; fwd=uri-miss", resp.Header.Get(CacheStatusHeader))
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_Revalidate(t *testing.T) {
+	c, clock, calls := newCachingMock(t, NewMemoryStore(1<<20), func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			resp := httpmock.NewStringResponse(http.StatusNotModified, "")
+			resp.Header.Set("Cache-Control", "max-age=30")
+			return resp, nil
+		}
+		return stringResponder(http.StatusOK, "v1", map[string]string{
+			"Cache-Control": "max-age=10",
+			"ETag":          `"v1"`,
+		})(req)
+	})
+
+	get(t, c)
+	clock.Advance(20 * time.Second)
+
+	resp, body := get(t, c)
+	assert.Equal(t, "v1", body)
+	assert.Equal(t, StatusRevalidated, StatusFromResponse(resp))
+	assert.Equal(t, int32(2), calls.Load())
+
+	// The refreshed freshness lifetime of the 304 response applies.
+	clock.Advance(20 * time.Second)
+	resp, _ = get(t, c)
+	assert.Equal(t, StatusHit, StatusFromResponse(resp))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_Vary(t *testing.T) {
+	c, _, calls := newCachingMock(t, NewMemoryStore(1<<20), func(req *http.Request) (*http.Response, error) {
+		return stringResponder(http.StatusOK, "hello "+req.Header.Get("Accept-Language"), map[string]string{
+			"Cache-Control": "max-age=60",
+			"Vary":          "Accept-Language",
+		})(req)
+	})
+
+	for _, lang := range []string{"en", "fr", "en", "fr"} {
+		_, body := get(t, c, httpclient.WithHeaders(map[string]string{"Accept-Language": lang}))
+		assert.Equal(t, "hello "+lang, body)
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	resp, _ := get(t, c, httpclient.WithHeaders(map[string]string{"Accept-Language": "de"}))
+	assert.Equal(t, "httpclient; fwd=vary-miss; stored", resp.Header.Get(CacheStatusHeader))
+}
+
+// slowStore delays the results of reads, so that concurrent read-modify-write updates of the same key overlap.
+type slowStore struct {
+	Store
+}
+
+func (s slowStore) Get(key string) ([]byte, bool, error) {
+	b, ok, err := s.Store.Get(key)
+	time.Sleep(10 * time.Millisecond)
+	return b, ok, err
+}
+
+func TestCache_ConcurrentVariants(t *testing.T) {
+	c, _, calls := newCachingMock(t, slowStore{NewMemoryStore(1 << 20)}, func(req *http.Request) (*http.Response, error) {
+		return stringResponder(http.StatusOK, "hello "+req.Header.Get("Accept-Language"), map[string]string{
+			"Cache-Control": "max-age=60",
+			"Vary":          "Accept-Language",
+		})(req)
+	})
+	languages := []string{"en", "fr", "de", "es"}
+
+	var wg sync.WaitGroup
+	for _, lang := range languages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, c, httpclient.WithHeaders(map[string]string{"Accept-Language": lang}))
+		}()
+	}
+	wg.Wait()
+
+	for _, lang := range languages {
+		resp, body := get(t, c, httpclient.WithHeaders(map[string]string{"Accept-Language": lang}))
+		assert.Equal(t, "hello "+lang, body)
+		assert.Equal(t, StatusHit, StatusFromResponse(resp), lang)
+	}
+	assert.Equal(t, int32(len(languages)), calls.Load())
+}
+
+func TestCache_UpstreamCacheStatus(t *testing.T) {
+	c, _, _ := newCachingMock(t, NewMemoryStore(1<<20), stringResponder(http.StatusOK, "OK", map[string]string{
+		"Cache-Control": "max-age=60",
+		"Cache-Status":  "OriginCache; hit; ttl=1100, CDN; fwd=uri-miss",
+	}))
+
+	resp, _ := get(t, c)
+	assert.Equal(t, "OriginCache; hit; ttl=1100, CDN; fwd=uri-miss, httpclient; fwd=uri-miss; stored",
+		resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+
+	resp, _ = get(t, c)
+	assert.Equal(t, "OriginCache; hit; ttl=1100, CDN; fwd=uri-miss, httpclient; hit; ttl=60",
+		resp.Header.Get(CacheStatusHeader))
+	assert.Equal(t, StatusHit, StatusFromResponse(resp))
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	version := &atomic.Int32{}
+	c, clock, calls := newCachingMock(t, NewMemoryStore(1<<20), func(req *http.Request) (*http.Response, error) {
+		body := "v1"
+		if version.Add(1) > 1 {
+			body = "v2"
+		}
+		return stringResponder(http.StatusOK, body, map[string]string{
+			"Cache-Control": "max-age=1, stale-while-revalidate=60",
+		})(req)
+	})
+
+	get(t, c)
+	clock.Advance(5 * time.Second)
+
+	resp, body := get(t, c)
+	assert.Equal(t, "v1", body)
+	assert.Equal(t, StatusStale, StatusFromResponse(resp))
+	assert.Equal(t, "httpclient; hit; ttl=-4", resp.Header.Get(CacheStatusHeader))
+
+	assert.Eventually(t, func() bool {
+		_, body := get(t, c)
+		return body == "v2"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_StaleIfError(t *testing.T) {
+	failing := &atomic.Bool{}
+	c, clock, _ := newCachingMock(t, NewMemoryStore(1<<20), func(req *http.Request) (*http.Response, error) {
+		if failing.Load() {
+			return httpmock.NewStringResponse(http.StatusServiceUnavailable, "unavailable"), nil
+		}
+		return stringResponder(http.StatusOK, "v1", map[string]string{
+			"Cache-Control": "max-age=1, stale-if-error=60",
+		})(req)
+	})
+
+	get(t, c)
+	failing.Store(true)
+	clock.Advance(30 * time.Second)
+
+	resp, body := get(t, c)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "v1", body)
+	assert.Equal(t, StatusStale, StatusFromResponse(resp))
+
+	clock.Advance(time.Minute)
+	resp, body = get(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "unavailable", body)
+	assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+}
+
+func TestCache_HeuristicFreshness(t *testing.T) {
+	lastModified := time.Date(2024, 4, 21, 12, 0, 0, 0, time.UTC)
+	c, clock, calls := newCachingMock(t, NewMemoryStore(1<<20),
+		stringResponder(http.StatusOK, "OK", map[string]string{"Last-Modified": lastModified.Format(http.TimeFormat)}))
+
+	get(t, c)
+	// 10% of the 10 days since the last modification.
+	clock.Advance(23 * time.Hour)
+	resp, _ := get(t, c)
+	assert.Equal(t, StatusHit, StatusFromResponse(resp))
+
+	clock.Advance(2 * time.Hour)
+	resp, _ = get(t, c)
+	assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCache_Directives(t *testing.T) {
+	tests := []struct {
+		name           string
+		responseHeader map[string]string
+		params         []httpclient.RequestParameter
+		wantStatus     Status
+		wantCalls      int32
+	}{
+		{
+			name:           "response no-store",
+			responseHeader: map[string]string{"Cache-Control": "no-store, max-age=60"},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "Vary asterisk",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60", "Vary": "*"},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "request no-store",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Cache-Control": "no-store"})},
+			wantStatus:     StatusBypass,
+			wantCalls:      2,
+		},
+		{
+			name:           "request no-cache",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Pragma": "no-cache"})},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "request max-age",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Cache-Control": "max-age=5"})},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "request min-fresh",
+			responseHeader: map[string]string{"Cache-Control": "max-age=60"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Cache-Control": "min-fresh=55"})},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "request max-stale",
+			responseHeader: map[string]string{"Cache-Control": "max-age=5"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Cache-Control": "max-stale=10"})},
+			wantStatus:     StatusStale,
+			wantCalls:      1,
+		},
+		{
+			name:           "must-revalidate",
+			responseHeader: map[string]string{"Cache-Control": "max-age=5, must-revalidate"},
+			params:         []httpclient.RequestParameter{httpclient.WithHeaders(map[string]string{"Cache-Control": "max-stale"})},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+		{
+			name:           "expired",
+			responseHeader: map[string]string{"Expires": "0"},
+			wantStatus:     StatusMiss,
+			wantCalls:      2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, clock, calls := newCachingMock(t, NewMemoryStore(1<<20),
+				stringResponder(http.StatusOK, "OK", tt.responseHeader))
+
+			get(t, c)
+			clock.Advance(10 * time.Second)
+			resp, body := get(t, c, tt.params...)
+			assert.Equal(t, "OK", body)
+			assert.Equal(t, tt.wantStatus, StatusFromResponse(resp))
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestCache_OnlyIfCached(t *testing.T) {
+	c, _, calls := newCachingMock(t, NewMemoryStore(1<<20),
+		stringResponder(http.StatusOK, "OK", map[string]string{"Cache-Control": "max-age=60"}))
+	onlyIfCached := httpclient.WithHeaders(map[string]string{"Cache-Control": "only-if-cached"})
+
+	resp, _ := get(t, c, onlyIfCached)
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+	assert.Equal(t, int32(0), calls.Load())
+
+	get(t, c)
+	resp, body := get(t, c, onlyIfCached)
+	assert.Equal(t, "OK", body)
+	assert.Equal(t, StatusHit, StatusFromResponse(resp))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	c, _, calls := newCachingMock(t, NewMemoryStore(1<<20),
+		stringResponder(http.StatusOK, "OK", map[string]string{"Cache-Control": "max-age=60"}))
+
+	get(t, c)
+	resp, err := c.Patch(context.Background(), testURL, strings.NewReader(`{"name":"updated"}`))
+	require.NoError(t, err)
+	assert.Equal(t, StatusBypass, StatusFromResponse(resp))
+
+	resp, _ = get(t, c)
+	assert.Equal(t, StatusMiss, StatusFromResponse(resp))
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestCurrentAge(t *testing.T) {
+	responseTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := &entry{
+		Header: http.Header{
+			"Date": []string{responseTime.Add(-3 * time.Second).Format(http.TimeFormat)},
+			"Age":  []string{"10"},
+		},
+		RequestTime:  responseTime.Add(-time.Second),
+		ResponseTime: responseTime,
+	}
+	// The Age header plus the response delay exceeds the apparent age.
+	assert.Equal(t, 16*time.Second, currentAge(e, responseTime.Add(5*time.Second)))
+
+	e.Header.Set("Age", "1")
+	assert.Equal(t, 8*time.Second, currentAge(e, responseTime.Add(5*time.Second)))
+}
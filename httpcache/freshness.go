@@ -0,0 +1,102 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heuristicFraction is the fraction of the time since the last modification used as heuristic freshness lifetime.
+const heuristicFraction = 0.1
+
+// heuristicallyCacheable are the status codes that can be cached without explicit freshness information.
+// See RFC 9110 Section 15.1.
+var heuristicallyCacheable = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+type directives map[string]string
+
+// parseCacheControl parses the Cache-Control header directives. Directive names are lowercased
+// and quoted values are unquoted.
+func parseCacheControl(h http.Header) directives {
+	d := directives{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			d[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns the value of a delta-seconds directive.
+func (d directives) seconds(name string) (time.Duration, bool) {
+	v, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshnessLifetime calculates the freshness lifetime of a response, see RFC 9111 Section 4.2.1.
+// Since this is a private cache, the s-maxage directive is ignored.
+func freshnessLifetime(e *entry, cc directives) time.Duration {
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+	date := e.date()
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			// Invalid values, such as "0", represent a time in the past.
+			return 0
+		}
+		return max(t.Sub(date), 0)
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil &&
+		(heuristicallyCacheable[e.StatusCode] || cc.has("public")) {
+		return max(time.Duration(float64(date.Sub(lastModified))*heuristicFraction), 0)
+	}
+	return 0
+}
+
+// hasExplicitExpiration reports whether the response carries explicit freshness information.
+func hasExplicitExpiration(h http.Header, cc directives) bool {
+	return cc.has("max-age") || h.Get("Expires") != ""
+}
+
+// currentAge calculates the age of a stored response, see RFC 9111 Section 4.2.3.
+func currentAge(e *entry, now time.Time) time.Duration {
+	apparentAge := max(e.ResponseTime.Sub(e.date()), 0)
+	var ageValue time.Duration
+	if age, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && age > 0 {
+		ageValue = time.Duration(age) * time.Second
+	}
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := max(apparentAge, ageValue+responseDelay)
+	return correctedInitialAge + now.Sub(e.ResponseTime)
+}
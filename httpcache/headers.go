@@ -0,0 +1,101 @@
+package httpcache
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/georgepsarakis/go-httpclient/internal/conditional"
+)
+
+// requestDirectives parses the request Cache-Control header. `Pragma: no-cache` is honored
+// only when Cache-Control is absent, see RFC 9111 Section 5.4.
+func requestDirectives(h http.Header) directives {
+	d := parseCacheControl(h)
+	if h.Get("Cache-Control") == "" && strings.Contains(strings.ToLower(h.Get("Pragma")), "no-cache") {
+		d["no-cache"] = ""
+	}
+	return d
+}
+
+// storable reports whether a response can be stored, see RFC 9111 Section 3.
+// Responses that would never be served or revalidated are not stored either.
+func storable(req *http.Request, resp *http.Response) bool {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode == http.StatusPartialContent ||
+		resp.StatusCode == http.StatusNotModified {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || requestDirectives(req.Header).has("no-store") {
+		return false
+	}
+	for _, name := range conditional.VaryFields(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	if !hasExplicitExpiration(resp.Header, cc) && !cc.has("public") && !heuristicallyCacheable[resp.StatusCode] {
+		return false
+	}
+	hasValidator := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	return hasValidator || hasExplicitExpiration(resp.Header, cc) && !cc.has("no-cache") ||
+		cc.has("stale-if-error") || cc.has("stale-while-revalidate")
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func sameVary(a, b http.Header) bool {
+	x, y := conditional.VaryFields(a), conditional.VaryFields(b)
+	sort.Strings(x)
+	sort.Strings(y)
+	return strings.Join(x, ",") == strings.Join(y, ",")
+}
+
+// nominatedHeaders copies the request header fields nominated by the Vary header of the response.
+func nominatedHeaders(reqHeader, respHeader http.Header) http.Header {
+	h := http.Header{}
+	for _, name := range conditional.VaryFields(respHeader) {
+		if values := reqHeader.Values(name); len(values) > 0 {
+			h[name] = append([]string(nil), values...)
+		}
+	}
+	return h
+}
+
+// normalizeHeader combines the values of a header field and removes insignificant whitespace.
+func normalizeHeader(h http.Header, name string) string {
+	var values []string
+	for _, line := range h.Values(name) {
+		for _, v := range strings.Split(line, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	resp := &http.Response{
+		Status:     "504 " + http.StatusText(http.StatusGatewayTimeout),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+	setStatus(resp, "fwd=miss", "detail=only-if-cached")
+	return resp
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
@@ -0,0 +1,86 @@
+package httpcache
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheStatusHeader is the response header that reports how the Cache handled a request, see RFC 9211.
+const CacheStatusHeader = "Cache-Status"
+
+// cacheName identifies this cache in the Cache-Status header.
+const cacheName = "httpclient"
+
+// Status summarizes the Cache-Status header of a response.
+type Status string
+
+const (
+	// StatusHit means that a fresh stored response was served without contacting the origin.
+	StatusHit Status = "hit"
+	// StatusStale means that a stale stored response was served, e.g. due to `stale-while-revalidate` or `stale-if-error`.
+	StatusStale Status = "stale"
+	// StatusRevalidated means that a stored response was served after the origin confirmed it with a 304 Not Modified.
+	StatusRevalidated Status = "revalidated"
+	// StatusMiss means that the response was fetched from the origin.
+	StatusMiss Status = "miss"
+	// StatusBypass means that the request was not eligible for caching.
+	StatusBypass Status = "bypass"
+)
+
+// StatusFromResponse returns the Status reported by the Cache on the response.
+// An empty Status is returned if the response did not pass through a Cache.
+func StatusFromResponse(resp *http.Response) Status {
+	var params map[string]string
+	for _, member := range strings.Split(resp.Header.Get(CacheStatusHeader), ",") {
+		name, rest, _ := strings.Cut(strings.TrimSpace(member), ";")
+		if strings.TrimSpace(name) != cacheName {
+			continue
+		}
+		params = map[string]string{}
+		for _, p := range strings.Split(rest, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			params[k] = v
+		}
+	}
+	if params == nil {
+		return ""
+	}
+	if ttl, err := strconv.Atoi(params["ttl"]); err == nil && ttl < 0 {
+		return StatusStale
+	}
+	if _, ok := params["hit"]; ok {
+		return StatusHit
+	}
+	switch params["fwd"] {
+	case "bypass", "method", "request":
+		return StatusBypass
+	case "stale":
+		if params["fwd-status"] == strconv.Itoa(http.StatusNotModified) {
+			return StatusRevalidated
+		}
+	}
+	return StatusMiss
+}
+
+// setStatus adds the entry of this cache to the Cache-Status header. Entries of upstream caches are preserved;
+// the list is ordered from the cache closest to the origin to the one closest to the client, see RFC 9211 Section 2.
+func setStatus(resp *http.Response, params ...string) {
+	status := strings.Join(append([]string{cacheName}, params...), "; ")
+	if upstream := resp.Header.Values(CacheStatusHeader); len(upstream) > 0 {
+		status = strings.Join(upstream, ", ") + ", " + status
+	}
+	resp.Header.Set(CacheStatusHeader, status)
+}
+
+// ttlParam formats the remaining freshness lifetime in whole seconds, rounded down so that
+// stale responses always report a negative value.
+func ttlParam(ttl time.Duration) string {
+	seconds := ttl / time.Second
+	if ttl%time.Second < 0 {
+		seconds--
+	}
+	return fmt.Sprintf("ttl=%d", int64(seconds))
+}
@@ -0,0 +1,154 @@
+package httpcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrTooLarge is returned by MemoryStore.Set for values that exceed the size budget of the store.
+var ErrTooLarge = errors.New("value exceeds the store size")
+
+// Store is the storage backend of the Cache. Values are opaque, serialized cache entries.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// MemoryStore is an in-memory least-recently-used Store, bounded by the total size of keys and values.
+type MemoryStore struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	order *list.List
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryStore creates a MemoryStore that holds at most `maxBytes` of keys and values.
+// Values larger than the budget are never stored; ErrTooLarge is returned instead.
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*memoryItem).value, true, nil
+}
+
+func (s *MemoryStore) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	itemSize := int64(len(key) + len(value))
+	if itemSize > s.maxBytes {
+		return ErrTooLarge
+	}
+	s.items[key] = s.order.PushFront(&memoryItem{key: key, value: value})
+	s.size += itemSize
+	for s.size > s.maxBytes {
+		s.remove(s.order.Back().Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(key)
+	return nil
+}
+
+// Size returns the total size of the stored keys and values.
+func (s *MemoryStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+func (s *MemoryStore) remove(key string) {
+	el, ok := s.items[key]
+	if !ok {
+		return
+	}
+	item := s.order.Remove(el).(*memoryItem)
+	delete(s.items, key)
+	s.size -= int64(len(item.key) + len(item.value))
+}
+
+// DiskStore is a Store that keeps each value in a separate file under a directory.
+// Files are named after the SHA-256 digest of the key and are replaced atomically.
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore creates a DiskStore in the given directory, which is created if it does not exist.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (s *DiskStore) Get(key string) ([]byte, bool, error) {
+	b, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (s *DiskStore) Set(key string, value []byte) error {
+	f, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(value); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
+
+func (s *DiskStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
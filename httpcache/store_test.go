@@ -0,0 +1,65 @@
+package httpcache
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(10)
+	require.NoError(t, s.Set("a", []byte("123")))
+	require.NoError(t, s.Set("b", []byte("456")))
+	assert.Equal(t, int64(8), s.Size())
+
+	// Reading "a" makes "b" the least recently used.
+	_, ok, err := s.Get("a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, s.Set("c", []byte("789")))
+	_, ok, _ = s.Get("b")
+	assert.False(t, ok)
+	v, ok, _ := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "123", string(v))
+	assert.Equal(t, int64(8), s.Size())
+
+	// Values over the budget are not stored.
+	require.ErrorIs(t, s.Set("d", []byte("0123456789")), ErrTooLarge)
+	_, ok, _ = s.Get("d")
+	assert.False(t, ok)
+
+	require.NoError(t, s.Delete("a"))
+	_, ok, _ = s.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(4), s.Size())
+}
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskStore(dir)
+	require.NoError(t, err)
+
+	_, ok, err := s.Get("GET https://api.example.com/")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Set("GET https://api.example.com/", []byte("v1")))
+	require.NoError(t, s.Set("GET https://api.example.com/", []byte("v2")))
+	v, ok, err := s.Get("GET https://api.example.com/")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "v2", string(v))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	require.NoError(t, s.Delete("GET https://api.example.com/"))
+	require.NoError(t, s.Delete("GET https://api.example.com/"))
+	_, ok, err = s.Get("GET https://api.example.com/")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,346 @@
+package httptesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BodyMatcher checks the body of a request, along with its headers, and returns a description of each difference.
+// The request matches if there are none.
+type BodyMatcher func(body []byte, header http.Header) []string
+
+// MatchBody requires the request body to satisfy all the given matchers.
+func (r *MockRequest) MatchBody(matchers ...BodyMatcher) *MockRequest {
+	r.bodyMatchers = append(r.bodyMatchers, matchers...)
+	return r
+}
+
+func (r *MockRequest) bodyMismatches(req *http.Request) []mismatch {
+	if len(r.bodyMatchers) == 0 {
+		return nil
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return []mismatch{{reason: fmt.Sprintf("body: failed to read: %v", err)}}
+	}
+	var m []mismatch
+	for _, match := range r.bodyMatchers {
+		for _, reason := range match(body, req.Header) {
+			m = append(m, mismatch{reason: "body: " + reason})
+		}
+	}
+	return m
+}
+
+// JSONOption configures the comparison of a JSONBody matcher.
+type JSONOption func(*jsonOptions)
+
+type jsonOptions struct {
+	partial   bool
+	unordered bool
+	ignored   map[string]bool
+}
+
+// PartialJSON allows objects in the request body to have fields that are not in the expected document,
+// and unordered arrays to have additional elements.
+func PartialJSON() JSONOption {
+	return func(o *jsonOptions) {
+		o.partial = true
+	}
+}
+
+// UnorderedArrays compares arrays regardless of the order of their elements.
+func UnorderedArrays() JSONOption {
+	return func(o *jsonOptions) {
+		o.unordered = true
+	}
+}
+
+// IgnoreFields excludes the given paths from the comparison, e.g. `$.meta.created_at` or `$.items[*].id`.
+// The `$.` prefix is optional.
+func IgnoreFields(paths ...string) JSONOption {
+	return func(o *jsonOptions) {
+		for _, p := range paths {
+			if !strings.HasPrefix(p, "$") {
+				p = "$." + p
+			}
+			o.ignored[p] = true
+		}
+	}
+}
+
+// JSONBody requires the request body to be a JSON document equal to the expected one, of any type.
+// Differences are reported by their path, e.g. `$.items[1].name`.
+func JSONBody(expected string, options ...JSONOption) BodyMatcher {
+	opts := jsonOptions{ignored: make(map[string]bool)}
+	for _, o := range options {
+		o(&opts)
+	}
+	var want any
+	wantErr := json.Unmarshal([]byte(expected), &want)
+	return func(body []byte, _ http.Header) []string {
+		if wantErr != nil {
+			return []string{fmt.Sprintf("invalid expected JSON: %v", wantErr)}
+		}
+		var got any
+		if err := json.Unmarshal(body, &got); err != nil {
+			return []string{fmt.Sprintf("invalid JSON: %v", err)}
+		}
+		var reasons []string
+		compareJSON("$", got, want, opts, &reasons)
+		return reasons
+	}
+}
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+func compareJSON(path string, got, want any, opts jsonOptions, reasons *[]string) {
+	if opts.ignored[path] || opts.ignored[arrayIndex.ReplaceAllString(path, "[*]")] {
+		return
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			*reasons = append(*reasons, fmt.Sprintf("%s: got %s, want an object", path, formatJSON(got)))
+			return
+		}
+		for _, key := range sortedJSONKeys(w, g) {
+			p := path + "." + key
+			gv, inGot := g[key]
+			wv, inWant := w[key]
+			switch {
+			case opts.ignored[p] || opts.ignored[arrayIndex.ReplaceAllString(p, "[*]")]:
+			case !inGot:
+				*reasons = append(*reasons, fmt.Sprintf("%s: missing, want %s", p, formatJSON(wv)))
+			case !inWant:
+				if !opts.partial {
+					*reasons = append(*reasons, fmt.Sprintf("%s: unexpected %s", p, formatJSON(gv)))
+				}
+			default:
+				compareJSON(p, gv, wv, opts, reasons)
+			}
+		}
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			*reasons = append(*reasons, fmt.Sprintf("%s: got %s, want an array", path, formatJSON(got)))
+			return
+		}
+		if opts.unordered {
+			compareUnordered(path, g, w, opts, reasons)
+			return
+		}
+		if len(g) != len(w) {
+			*reasons = append(*reasons, fmt.Sprintf("%s: got %d elements, want %d", path, len(g), len(w)))
+			return
+		}
+		for i := range w {
+			compareJSON(fmt.Sprintf("%s[%d]", path, i), g[i], w[i], opts, reasons)
+		}
+	default:
+		if !reflect.DeepEqual(got, want) {
+			*reasons = append(*reasons, fmt.Sprintf("%s: got %s, want %s", path, formatJSON(got), formatJSON(want)))
+		}
+	}
+}
+
+// compareUnordered matches every expected element with a distinct element of the array. Since an element may match
+// several expected ones, e.g. with PartialJSON, the pairs are chosen by a maximum bipartite matching.
+func compareUnordered(path string, got, want []any, opts jsonOptions, reasons *[]string) {
+	if len(got) < len(want) || (!opts.partial && len(got) != len(want)) {
+		*reasons = append(*reasons, fmt.Sprintf("%s: got %d elements, want %d", path, len(got), len(want)))
+		return
+	}
+	matches := make([][]bool, len(want))
+	for i, w := range want {
+		matches[i] = make([]bool, len(got))
+		for j, g := range got {
+			var r []string
+			compareJSON(path+"[*]", g, w, opts, &r)
+			matches[i][j] = len(r) == 0
+		}
+	}
+	// matchedWant holds the expected element paired with each element of the array, or -1.
+	matchedWant := make([]int, len(got))
+	for j := range matchedWant {
+		matchedWant[j] = -1
+	}
+	// assign pairs the expected element i with an element of the array, moving previously paired
+	// expected elements to other elements along an augmenting path if necessary.
+	var assign func(i int, visited []bool) bool
+	assign = func(i int, visited []bool) bool {
+		for j, ok := range matches[i] {
+			if !ok || visited[j] {
+				continue
+			}
+			visited[j] = true
+			if matchedWant[j] < 0 || assign(matchedWant[j], visited) {
+				matchedWant[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i, w := range want {
+		if !assign(i, make([]bool, len(got))) {
+			*reasons = append(*reasons, fmt.Sprintf("%s: no element matches %s", path, formatJSON(w)))
+		}
+	}
+}
+
+func sortedJSONKeys(objects ...map[string]any) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, o := range objects {
+		for k := range o {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// JSONPathBody requires the value at the JSONPath expression to be equal to the expected value, which is compared
+// as JSON, e.g. `JSONPathBody("$.items[0].id", 42)`. Expressions with wildcards, e.g. `$.items[*].id`,
+// select an array of values. The supported syntax consists of `$`, `.name`, `['name']`, `[index]`, `.*` and `[*]`.
+func JSONPathBody(expr string, expected any) BodyMatcher {
+	path, pathErr := parseJSONPath(expr)
+	var want any
+	b, wantErr := json.Marshal(expected)
+	if wantErr == nil {
+		wantErr = json.Unmarshal(b, &want)
+	}
+	return func(body []byte, _ http.Header) []string {
+		if pathErr != nil {
+			return []string{pathErr.Error()}
+		}
+		if wantErr != nil {
+			return []string{fmt.Sprintf("invalid expected value: %v", wantErr)}
+		}
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return []string{fmt.Sprintf("invalid JSON: %v", err)}
+		}
+		got, found := path.evaluate(doc)
+		if !found {
+			return []string{fmt.Sprintf("%s: not found", expr)}
+		}
+		if !reflect.DeepEqual(got, want) {
+			return []string{fmt.Sprintf("%s: got %s, want %s", expr, formatJSON(got), formatJSON(want))}
+		}
+		return nil
+	}
+}
+
+// FormBody requires the request body to be URL-encoded form data that includes the expected fields.
+// Values are compared regardless of their order, and AnyValue matches any value.
+func FormBody(expected url.Values) BodyMatcher {
+	return func(body []byte, _ http.Header) []string {
+		got, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []string{fmt.Sprintf("invalid form data: %v", err)}
+		}
+		var reasons []string
+		for _, name := range sortedKeys(expected) {
+			switch g, w := got[name], expected[name]; {
+			case len(g) == 0:
+				reasons = append(reasons, fmt.Sprintf("form %s: missing, want %q", name, w))
+			case !matchQueryValues(g, w):
+				reasons = append(reasons, fmt.Sprintf("form %s: got %q, want %q", name, g, w))
+			}
+		}
+		return reasons
+	}
+}
+
+// MultipartPart requires the multipart request body to have a part with the given form name,
+// whose content and headers satisfy the given matchers, e.g. `MultipartPart("metadata", JSONBody(...))`.
+func MultipartPart(name string, matchers ...BodyMatcher) BodyMatcher {
+	return func(body []byte, header http.Header) []string {
+		mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+			return []string{fmt.Sprintf("got Content-Type %q, want multipart", header.Get("Content-Type"))}
+		}
+		mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return []string{fmt.Sprintf("multipart part %s: missing", name)}
+			}
+			if err != nil {
+				return []string{fmt.Sprintf("invalid multipart body: %v", err)}
+			}
+			if part.FormName() != name {
+				continue
+			}
+			content, err := io.ReadAll(part)
+			if err != nil {
+				return []string{fmt.Sprintf("multipart part %s: %v", name, err)}
+			}
+			var reasons []string
+			for _, match := range matchers {
+				for _, reason := range match(content, http.Header(part.Header)) {
+					reasons = append(reasons, fmt.Sprintf("multipart part %s: %s", name, reason))
+				}
+			}
+			return reasons
+		}
+	}
+}
+
+// TextBody requires the request body to be equal to the expected text.
+func TextBody(expected string) BodyMatcher {
+	return func(body []byte, _ http.Header) []string {
+		if string(body) != expected {
+			return []string{fmt.Sprintf("got %q, want %q", body, expected)}
+		}
+		return nil
+	}
+}
+
+// BodyRegexp requires the request body to match the regular expression.
+func BodyRegexp(pattern string) BodyMatcher {
+	re, err := regexp.Compile(pattern)
+	return func(body []byte, _ http.Header) []string {
+		if err != nil {
+			return []string{fmt.Sprintf("invalid pattern %q: %v", pattern, err)}
+		}
+		if !re.Match(body) {
+			return []string{fmt.Sprintf("got %q, want text matching %q", body, pattern)}
+		}
+		return nil
+	}
+}
+
+// BodyFunc requires the request body to satisfy the predicate; the returned error describes the difference.
+func BodyFunc(predicate func(body []byte) error) BodyMatcher {
+	return func(body []byte, _ http.Header) []string {
+		if err := predicate(body); err != nil {
+			return []string{err.Error()}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,218 @@
+package httptesting
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyMatchers(t *testing.T) {
+	form := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	require.NoError(t, mw.WriteField("metadata", `{"name":"report","size":3}`))
+	fw, err := mw.CreateFormFile("file", "report.csv")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("a,b"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	multipartHeader := http.Header{"Content-Type": {mw.FormDataContentType()}}
+
+	tests := []struct {
+		name    string
+		matcher BodyMatcher
+		body    string
+		header  http.Header
+		reasons []string
+	}{
+		{
+			name:    "JSON",
+			matcher: JSONBody(`{"name":"a","tags":["x","y"]}`),
+			body:    `{"tags":["x","y"],"name":"a"}`,
+		},
+		{
+			name:    "JSON differences",
+			matcher: JSONBody(`{"name":"a","tags":["x","y"],"owner":{"id":1}}`),
+			body:    `{"name":"b","tags":["x"],"owner":{"id":"1"},"extra":true}`,
+			reasons: []string{
+				`$.extra: unexpected true`,
+				`$.name: got "b", want "a"`,
+				`$.owner.id: got "1", want 1`,
+				`$.tags: got 1 elements, want 2`,
+			},
+		},
+		{
+			name:    "JSON array",
+			matcher: JSONBody(`[{"id":1},{"id":2}]`),
+			body:    `[{"id":1},{"id":3}]`,
+			reasons: []string{`$[1].id: got 3, want 2`},
+		},
+		{
+			name:    "partial JSON",
+			matcher: JSONBody(`{"user":{"name":"a"}}`, PartialJSON()),
+			body:    `{"user":{"name":"a","id":7},"request_id":"abc"}`,
+		},
+		{
+			name:    "unordered arrays",
+			matcher: JSONBody(`{"ids":[3,1]}`, UnorderedArrays(), PartialJSON()),
+			body:    `{"ids":[1,2,3]}`,
+		},
+		{
+			name:    "unordered arrays mismatch",
+			matcher: JSONBody(`{"ids":[3,4]}`, UnorderedArrays()),
+			body:    `{"ids":[1,3]}`,
+			reasons: []string{`$.ids: no element matches 4`},
+		},
+		{
+			name:    "unordered partial arrays",
+			matcher: JSONBody(`[{"a":1},{"a":1,"b":2}]`, PartialJSON(), UnorderedArrays()),
+			body:    `[{"a":1,"b":2},{"a":1}]`,
+		},
+		{
+			name:    "unordered partial arrays mismatch",
+			matcher: JSONBody(`[{"a":1},{"a":1,"b":2}]`, PartialJSON(), UnorderedArrays()),
+			body:    `[{"a":1,"b":3},{"a":1}]`,
+			reasons: []string{`$: no element matches {"a":1,"b":2}`},
+		},
+		{
+			name:    "ignored fields",
+			matcher: JSONBody(`{"id":1,"items":[{"name":"a","created_at":""}]}`, IgnoreFields("created_at", "$.items[*].created_at", "id")),
+			body:    `{"items":[{"name":"a","created_at":"2024-01-01"}],"created_at":"2024-01-01"}`,
+		},
+		{
+			name:    "invalid JSON",
+			matcher: JSONBody(`{}`),
+			body:    `name=a`,
+			reasons: []string{`invalid JSON: invalid character 'a' in literal null (expecting 'u')`},
+		},
+		{
+			name:    "JSONPath",
+			matcher: JSONPathBody("$.items[1]['name']", "b"),
+			body:    `{"items":[{"name":"a"},{"name":"b"}]}`,
+		},
+		{
+			name:    "JSONPath wildcard",
+			matcher: JSONPathBody("$.items[*].id", []int{1, 2}),
+			body:    `{"items":[{"id":1},{"id":3}]}`,
+			reasons: []string{`$.items[*].id: got [1,3], want [1,2]`},
+		},
+		{
+			name:    "JSONPath not found",
+			matcher: JSONPathBody("$.items[5]", nil),
+			body:    `{"items":[]}`,
+			reasons: []string{`$.items[5]: not found`},
+		},
+		{
+			name:    "invalid JSONPath",
+			matcher: JSONPathBody("items", nil),
+			body:    `{}`,
+			reasons: []string{`invalid JSONPath "items": must start with $`},
+		},
+		{
+			name:    "form",
+			matcher: FormBody(url.Values{"grant_type": {"client_credentials"}, "scope": {"write", "read"}, "nonce": {AnyValue}}),
+			body:    "grant_type=client_credentials&scope=read&scope=write&nonce=123&extra=1",
+			header:  form,
+		},
+		{
+			name:    "form differences",
+			matcher: FormBody(url.Values{"grant_type": {"client_credentials"}, "scope": {"read"}}),
+			body:    "grant_type=password",
+			header:  form,
+			reasons: []string{
+				`form grant_type: got ["password"], want ["client_credentials"]`,
+				`form scope: missing, want ["read"]`,
+			},
+		},
+		{
+			name:    "multipart",
+			matcher: MultipartPart("metadata", JSONBody(`{"name":"report"}`, PartialJSON())),
+			body:    multipartBody.String(),
+			header:  multipartHeader,
+		},
+		{
+			name:    "multipart differences",
+			matcher: MultipartPart("file", TextBody("a,c")),
+			body:    multipartBody.String(),
+			header:  multipartHeader,
+			reasons: []string{`multipart part file: got "a,b", want "a,c"`},
+		},
+		{
+			name:    "multipart missing part",
+			matcher: MultipartPart("avatar"),
+			body:    multipartBody.String(),
+			header:  multipartHeader,
+			reasons: []string{`multipart part avatar: missing`},
+		},
+		{
+			name:    "not multipart",
+			matcher: MultipartPart("file"),
+			body:    "a,b",
+			header:  http.Header{"Content-Type": {"text/csv"}},
+			reasons: []string{`got Content-Type "text/csv", want multipart`},
+		},
+		{
+			name:    "regexp",
+			matcher: BodyRegexp(`^hello \w+$`),
+			body:    "hello, world",
+			reasons: []string{`got "hello, world", want text matching "^hello \\w+$"`},
+		},
+		{
+			name: "predicate",
+			matcher: BodyFunc(func(body []byte) error {
+				if len(body) > 3 {
+					return errors.New("body longer than 3 bytes")
+				}
+				return nil
+			}),
+			body:    "abcd",
+			reasons: []string{"body longer than 3 bytes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.reasons, tt.matcher([]byte(tt.body), tt.header))
+		})
+	}
+}
+
+func TestMockRequest_MatchBody(t *testing.T) {
+	ft := &failureRecorder{TB: t}
+	m := NewMock(ft)
+	created := m.NewMockRequest(http.MethodPost, "http://localhost/users").
+		MatchBody(JSONBody(`{"name":"a"}`, PartialJSON())).
+		RespondWithJSON(http.StatusCreated, `{"id":1}`)
+	created.Register()
+
+	resp, err := m.Post(context.Background(), "http://localhost/users", strings.NewReader(`{"name":"a","age":3}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	_, err = m.Post(context.Background(), "http://localhost/users", strings.NewReader(`{"name":"b"}`))
+	require.Error(t, err)
+	m.AssertExpectations()
+	require.Len(t, ft.failures, 1)
+	assert.True(t, strings.HasSuffix(ft.failures[0], "\n  - body: $.name: got \"b\", want \"a\""), ft.failures[0])
+	assert.Equal(t, 1, created.Calls())
+}
+
+func TestMock_NewJSONMatcher(t *testing.T) {
+	m := NewMock(t)
+	m.Transport().RegisterMatcherResponder(http.MethodPost, "http://localhost/batch",
+		httpmock.NewMatcher("batch", m.NewJSONMatcher(`[{"id":1}]`)), httpmock.NewStringResponder(http.StatusOK, "OK"))
+	m.AllowUnmatchedRequests()
+
+	_, err := m.Post(context.Background(), "http://localhost/batch", strings.NewReader(`[{"id":1}]`))
+	require.NoError(t, err)
+	_, err = m.Post(context.Background(), "http://localhost/batch", strings.NewReader(`not json`))
+	require.Error(t, err)
+}
@@ -0,0 +1,437 @@
+package httptesting
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+// CassetteMode determines whether a Cassette records real interactions or replays recorded ones.
+type CassetteMode int
+
+const (
+	// ModeAuto replays the cassette file if it exists, otherwise records a new one.
+	ModeAuto CassetteMode = iota
+	// ModeRecord sends every request to the real transport and overwrites the cassette file at cleanup.
+	ModeRecord
+	// ModeReplay replays the cassette file, which must exist. Requests are never sent to the real transport:
+	// requests that match no recorded interaction fail with ErrUnrecordedRequest, and the file is never modified.
+	ModeReplay
+)
+
+// ErrUnrecordedRequest is returned for requests that match no recorded interaction, in ModeReplay
+// or by a strict Cassette.
+var ErrUnrecordedRequest = errors.New("httptesting: request not recorded in cassette")
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request" yaml:"request"`
+	Response RecordedResponse `json:"response" yaml:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string      `json:"method" yaml:"method"`
+	URL     string      `json:"url" yaml:"url"`
+	Headers http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body    string      `json:"body,omitempty" yaml:"body,omitempty"`
+	// BodyEncoding is `base64` for bodies that are not valid UTF-8.
+	BodyEncoding string `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code" yaml:"status_code"`
+	Headers      http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// InteractionMatcher reports whether an incoming request matches a recorded one.
+// The incoming request has already been scrubbed, so that it is comparable with the recorded request.
+type InteractionMatcher func(req, recorded RecordedRequest) bool
+
+// MatchMethod matches requests with the same method.
+func MatchMethod(req, recorded RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same URL. Query parameters are compared regardless of their order.
+func MatchURL(req, recorded RecordedRequest) bool {
+	u1, err1 := url.Parse(req.URL)
+	u2, err2 := url.Parse(recorded.URL)
+	if err1 != nil || err2 != nil {
+		return req.URL == recorded.URL
+	}
+	q1, q2 := u1.Query(), u2.Query()
+	u1.RawQuery, u2.RawQuery = "", ""
+	return u1.String() == u2.String() && q1.Encode() == q2.Encode()
+}
+
+// MatchBody matches requests with the same body.
+func MatchBody(req, recorded RecordedRequest) bool {
+	return req.Body == recorded.Body && req.BodyEncoding == recorded.BodyEncoding
+}
+
+// MatchHeaders matches requests with the same values for the given headers.
+func MatchHeaders(names ...string) InteractionMatcher {
+	return func(req, recorded RecordedRequest) bool {
+		for _, name := range names {
+			if strings.Join(req.Headers.Values(name), ",") != strings.Join(recorded.Headers.Values(name), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Scrubber removes secrets from an interaction before it is saved. Scrubbers are also applied to incoming requests
+// before they are matched against the recorded interactions.
+type Scrubber func(interaction *Interaction)
+
+// ScrubHeaders replaces the values of the given request and response headers with httpclient.RedactedValue.
+func ScrubHeaders(names ...string) Scrubber {
+	return func(interaction *Interaction) {
+		for _, name := range names {
+			for _, h := range []http.Header{interaction.Request.Headers, interaction.Response.Headers} {
+				if h.Get(name) != "" {
+					h.Set(name, httpclient.RedactedValue)
+				}
+			}
+		}
+	}
+}
+
+// ScrubQueryParameters replaces the values of the given query parameters with httpclient.RedactedValue.
+func ScrubQueryParameters(names ...string) Scrubber {
+	return func(interaction *Interaction) {
+		u, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			return
+		}
+		query := u.Query()
+		scrubbed := false
+		for _, name := range names {
+			if query.Has(name) {
+				query.Set(name, httpclient.RedactedValue)
+				scrubbed = true
+			}
+		}
+		if scrubbed {
+			u.RawQuery = query.Encode()
+			interaction.Request.URL = u.String()
+		}
+	}
+}
+
+// Cassette records real HTTP interactions to a file and replays them in later test runs.
+// The file format is YAML, or JSON if the file name has the `.json` extension.
+//
+// Requests are matched on their method and URL by default. In ModeAuto, unmatched requests are sent to the real
+// transport and recorded, unless the cassette is strict. Sensitive headers and query parameters, as listed in
+// httpclient.DefaultRedactedHeaders and httpclient.DefaultRedactedQueryParameters, are scrubbed.
+type Cassette struct {
+	t         testing.TB
+	path      string
+	mode      CassetteMode
+	strict    bool
+	real      http.RoundTripper
+	matchers  []InteractionMatcher
+	scrubbers []Scrubber
+
+	mu           sync.Mutex
+	loaded       bool
+	replaying    bool
+	interactions []Interaction
+	used         []bool
+	modified     bool
+}
+
+// NewCassette creates a Cassette stored in the given file. The cassette is saved when the test and its subtests
+// complete, if new interactions were recorded.
+func NewCassette(t testing.TB, path string) *Cassette {
+	c := &Cassette{
+		t:        t,
+		path:     path,
+		real:     http.DefaultTransport,
+		matchers: []InteractionMatcher{MatchMethod, MatchURL},
+		scrubbers: []Scrubber{
+			ScrubHeaders(httpclient.DefaultRedactedHeaders...),
+			ScrubQueryParameters(httpclient.DefaultRedactedQueryParameters...),
+		},
+	}
+	t.Cleanup(func() {
+		if err := c.save(); err != nil {
+			t.Errorf("httptesting: failed to save cassette %s: %v", c.path, err)
+		}
+	})
+	return c
+}
+
+// WithMode sets the CassetteMode; ModeAuto is the default.
+func (c *Cassette) WithMode(mode CassetteMode) *Cassette {
+	c.mode = mode
+	return c
+}
+
+// Strict fails the test, and the request, when a request matches no recorded interaction while replaying.
+// In ModeReplay, such requests always fail; Strict also fails the test.
+func (c *Cassette) Strict() *Cassette {
+	c.strict = true
+	return c
+}
+
+// WithRealTransport sets the transport used for recording; http.DefaultTransport is the default.
+func (c *Cassette) WithRealTransport(rt http.RoundTripper) *Cassette {
+	c.real = rt
+	return c
+}
+
+// WithMatchers replaces the InteractionMatchers; a request matches an interaction if all the matchers match.
+func (c *Cassette) WithMatchers(matchers ...InteractionMatcher) *Cassette {
+	c.matchers = matchers
+	return c
+}
+
+// WithScrubbers adds Scrubbers, applied after the default ones.
+func (c *Cassette) WithScrubbers(scrubbers ...Scrubber) *Cassette {
+	c.scrubbers = append(c.scrubbers, scrubbers...)
+	return c
+}
+
+// Client returns a Client that uses the cassette as its transport.
+func (c *Cassette) Client() *httpclient.Client {
+	return httpclient.NewWithTransport(c)
+}
+
+// Interactions returns the interactions of the cassette.
+func (c *Cassette) Interactions() ([]Interaction, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return append([]Interaction(nil), c.interactions...), nil
+}
+
+// RoundTrip replays the first unused recorded interaction that matches the request, or the last matching one
+// if all of them have been used. Unmatched requests are recorded in ModeAuto, unless the cassette is strict,
+// and fail with ErrUnrecordedRequest otherwise.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	incoming := Interaction{Request: RecordedRequest{Method: req.Method, URL: req.URL.String(), Headers: req.Header.Clone()}}
+	incoming.Request.Body, incoming.Request.BodyEncoding = encodeBody(body)
+	c.scrub(&incoming)
+
+	c.mu.Lock()
+	if err := c.load(); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	replaying := c.replaying
+	if replaying {
+		if i := c.match(incoming.Request); i >= 0 {
+			c.used[i] = true
+			recorded := c.interactions[i].Response
+			c.mu.Unlock()
+			return replay(req, recorded)
+		}
+	}
+	c.mu.Unlock()
+
+	if replaying && (c.mode == ModeReplay || c.strict) {
+		if c.strict {
+			curl, _ := httpclient.ToCurl(req)
+			c.t.Errorf("httptesting: unrecorded request in cassette %s:\n%s", c.path, curl)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrUnrecordedRequest, req.Method, req.URL)
+	}
+	return c.record(req, incoming)
+}
+
+func (c *Cassette) record(req *http.Request, interaction Interaction) (*http.Response, error) {
+	resp, err := c.real.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	interaction.Response = RecordedResponse{StatusCode: resp.StatusCode, Headers: resp.Header.Clone()}
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(body)
+	c.scrub(&interaction)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	c.used = append(c.used, true)
+	c.modified = true
+	return resp, nil
+}
+
+func (c *Cassette) scrub(interaction *Interaction) {
+	if interaction.Request.Headers == nil {
+		interaction.Request.Headers = http.Header{}
+	}
+	for _, scrub := range c.scrubbers {
+		scrub(interaction)
+	}
+}
+
+func (c *Cassette) match(req RecordedRequest) int {
+	last := -1
+	for i, interaction := range c.interactions {
+		if !c.matches(req, interaction.Request) {
+			continue
+		}
+		if !c.used[i] {
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+func (c *Cassette) matches(req, recorded RecordedRequest) bool {
+	for _, m := range c.matchers {
+		if !m(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Cassette) load() error {
+	if c.loaded {
+		return nil
+	}
+	if c.mode == ModeRecord {
+		c.loaded = true
+		return nil
+	}
+	b, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) && c.mode == ModeAuto {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f cassetteFile
+	if c.isJSON() {
+		err = json.Unmarshal(b, &f)
+	} else {
+		err = yaml.Unmarshal(b, &f)
+	}
+	if err != nil {
+		return fmt.Errorf("httptesting: invalid cassette %s: %w", c.path, err)
+	}
+	c.interactions = f.Interactions
+	c.used = make([]bool, len(f.Interactions))
+	c.loaded, c.replaying = true, true
+	return nil
+}
+
+func (c *Cassette) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.modified {
+		return nil
+	}
+	f := cassetteFile{Interactions: c.interactions}
+	var b []byte
+	var err error
+	if c.isJSON() {
+		b, err = json.MarshalIndent(f, "", "  ")
+		b = append(b, '\n')
+	} else {
+		b, err = yaml.Marshal(f)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, b, 0o644)
+}
+
+func (c *Cassette) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(c.path), ".json")
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions" yaml:"interactions"`
+}
+
+func replay(req *http.Request, recorded RecordedResponse) (*http.Response, error) {
+	body, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(recorded.StatusCode) + " " + http.StatusText(recorded.StatusCode),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// requestBody returns the request body, restoring it if it is not replayable.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	return httpclient.InterceptRequestBody(req)
+}
+
+func encodeBody(b []byte) (body, encoding string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
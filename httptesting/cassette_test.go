@@ -0,0 +1,162 @@
+package httptesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+func newCassetteServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=s3cr3t")
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%d %s %s %s", n, r.Method, r.URL.Path, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestCassette(t *testing.T) {
+	for _, name := range []string{"users.yaml", "users.json"} {
+		t.Run(name, func(t *testing.T) {
+			srv, calls := newCassetteServer(t)
+			path := filepath.Join(t.TempDir(), "fixtures", name)
+
+			t.Run("record", func(t *testing.T) {
+				c := NewCassette(t, path).Client()
+				resp, err := c.Get(context.Background(), srv.URL+"/users?access_token=abc&page=1",
+					httpclient.WithHeaders(map[string]string{"Authorization": "Bearer token"}))
+				require.NoError(t, err)
+				assert.Equal(t, "1 GET /users ", string(httpclient.MustInterceptResponseBody(resp)))
+				resp, err = c.Post(context.Background(), srv.URL+"/users", strings.NewReader(`{"name":"a"}`))
+				require.NoError(t, err)
+				assert.Equal(t, `2 POST /users {"name":"a"}`, string(httpclient.MustInterceptResponseBody(resp)))
+			})
+
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.NotContains(t, string(b), "s3cr3t")
+			assert.NotContains(t, string(b), "Bearer token")
+			assert.NotContains(t, string(b), "abc")
+
+			t.Run("replay", func(t *testing.T) {
+				cassette := NewCassette(t, path).Strict()
+				c := cassette.Client()
+				// Query parameters are matched regardless of their order, after scrubbing.
+				resp, err := c.Get(context.Background(), srv.URL+"/users?page=1&access_token=xyz")
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+				assert.Equal(t, httpclient.RedactedValue, resp.Header.Get("Set-Cookie"))
+				assert.Equal(t, "1 GET /users ", string(httpclient.MustInterceptResponseBody(resp)))
+				resp, err = c.Post(context.Background(), srv.URL+"/users", strings.NewReader(`{"name":"b"}`))
+				require.NoError(t, err)
+				assert.Equal(t, `2 POST /users {"name":"a"}`, string(httpclient.MustInterceptResponseBody(resp)))
+
+				interactions, err := cassette.Interactions()
+				require.NoError(t, err)
+				assert.Len(t, interactions, 2)
+			})
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	}
+}
+
+// failureRecorder captures the failures reported by a Cassette instead of failing the test.
+type failureRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *failureRecorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestCassette_Strict(t *testing.T) {
+	srv, calls := newCassetteServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	t.Run("record", func(t *testing.T) {
+		_, err := NewCassette(t, path).Client().Get(context.Background(), srv.URL+"/users")
+		require.NoError(t, err)
+	})
+
+	ft := &failureRecorder{TB: t}
+	c := NewCassette(ft, path).
+		WithMatchers(MatchMethod, MatchURL, MatchBody).
+		Strict().
+		Client()
+	_, err := c.Get(context.Background(), srv.URL+"/users/1")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrUnrecordedRequest))
+	require.Len(t, ft.failures, 1)
+	assert.Contains(t, ft.failures[0], "curl '"+srv.URL+"/users/1'")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCassette_ReplayModeNeverRecords(t *testing.T) {
+	srv, calls := newCassetteServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	t.Run("record", func(t *testing.T) {
+		_, err := NewCassette(t, path).Client().Get(context.Background(), srv.URL+"/users")
+		require.NoError(t, err)
+	})
+	recorded, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	t.Run("replay", func(t *testing.T) {
+		c := NewCassette(t, path).WithMode(ModeReplay).Client()
+		_, err := c.Get(context.Background(), srv.URL+"/users")
+		require.NoError(t, err)
+		_, err = c.Get(context.Background(), srv.URL+"/users/1")
+		assert.ErrorIs(t, err, ErrUnrecordedRequest)
+	})
+
+	assert.Equal(t, int32(1), calls.Load())
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(recorded), string(saved))
+}
+
+func TestCassette_NewInteractions(t *testing.T) {
+	srv, calls := newCassetteServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	withoutDate := func(interaction *Interaction) {
+		interaction.Response.Headers.Del("Date")
+	}
+	for i := 0; i < 2; i++ {
+		t.Run("run", func(t *testing.T) {
+			c := NewCassette(t, path).WithScrubbers(withoutDate).Client()
+			_, err := c.Get(context.Background(), srv.URL+"/a")
+			require.NoError(t, err)
+			_, err = c.Get(context.Background(), srv.URL+"/b", httpclient.WithHeaders(map[string]string{"Accept": "text/plain"}))
+			require.NoError(t, err)
+		})
+	}
+	assert.Equal(t, int32(2), calls.Load())
+
+	t.Run("record mode", func(t *testing.T) {
+		_, err := NewCassette(t, path).WithMode(ModeRecord).WithScrubbers(withoutDate).Client().Get(context.Background(), srv.URL+"/c")
+		require.NoError(t, err)
+	})
+	interactions, err := NewCassette(t, path).WithMode(ModeReplay).Interactions()
+	require.NoError(t, err)
+	require.Len(t, interactions, 1)
+	assert.Equal(t, srv.URL+"/c", interactions[0].Request.URL)
+	assert.Empty(t, interactions[0].Response.Headers.Get("Date"))
+}
@@ -1,15 +1,17 @@
 package httptesting
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/georgepsarakis/go-httpclient"
@@ -18,36 +20,89 @@ import (
 type Mock struct {
 	*httpclient.Client
 	mock *httpmock.MockTransport
-	t    *testing.T
+	t    testing.TB
+	// baseURL resolves relative registration URLs.
+	baseURL *url.URL
+
+	mu             sync.Mutex
+	sequence       int
+	registered     []*MockRequest
+	unmatched      []*unmatchedRequest
+	allowUnmatched bool
 }
 
-func NewMock(t *testing.T) *Mock {
-	mt := httpmock.NewMockTransport()
-	return &Mock{
-		Client: httpclient.NewWithTransport(mt),
-		mock:   mt,
-		t:      t,
+// NewMock creates a Mock whose expectations are verified when the test completes; see AssertExpectations.
+func NewMock(t testing.TB) *Mock {
+	m := newMock(t)
+	m.Client = httpclient.NewWithTransport(m.transport())
+	return m
+}
+
+func newMock(t testing.TB) *Mock {
+	m := &Mock{
+		mock: httpmock.NewMockTransport(),
+		t:    t,
 	}
+	t.Cleanup(m.AssertExpectations)
+	return m
 }
 
 // Transport exposes the httpmock.MockTransport instance for advanced usage.
+//
+// Registered MockRequests share a single `=~.*` route per method, so that they are evaluated in registration order
+// regardless of their URLs. As a result, GetCallCountInfo reports each matched request twice: under the requested URL,
+// e.g. `GET http://localhost/users/42 <matcher>`, and under `GET =~.* <matcher>`, where the matcher name contains
+// the URL of the MockRequest. Prefer MockRequest.Calls for the number of requests that matched a mock.
 func (c *Mock) Transport() *httpmock.MockTransport {
 	return c.mock
 }
 
+// transport records the requests that match no registered mock and appends their curl equivalent
+// to the returned error, so that the request can be compared with the mocks or reproduced against the real service.
+func (c *Mock) transport() http.RoundTripper {
+	return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := c.mock.RoundTrip(req)
+		if err != nil && errors.Is(err, httpmock.NoResponderFound) {
+			u := c.recordUnmatched(req)
+			if u.curl != "" {
+				return resp, fmt.Errorf("%w\n%s", err, u.curl)
+			}
+		}
+		return resp, err
+	})
+}
+
 type MockRequest struct {
 	req            *http.Request
+	headers        http.Header
+	exactHeaders   bool
+	headerMatchers []headerMatcher
+	partialQuery   bool
+	bodyMatchers   []BodyMatcher
 	requestMatcher httpmock.Matcher
 	responder      httpmock.Responder
-	t              *testing.T
-	mockTransport  *httpmock.MockTransport
+	t              testing.TB
+	mock           *Mock
+	expected       expectation
+	calls          atomic.Int32
 }
 
 type MockResponse httpmock.Responder
 
+// NewMockRequest creates a MockRequest for the given method and URL, which is expected to be called at least once
+// once registered. Use Times, AtLeast, Once or Never in order to change the expectation.
+// The URL path may contain `{name}` segments, which match any single segment, and query parameters match
+// regardless of their order; use PartialQuery in order to allow additional query parameters.
+// Requests match if they include the headers given with the WithHeaders functional option parameter;
+// use ExactHeaders in order to reject additional headers.
 func (c *Mock) NewMockRequest(method, url string, params ...httpclient.RequestParameter) *MockRequest {
 	c.t.Helper()
 
+	if c.baseURL != nil {
+		ref, err := c.baseURL.Parse(url)
+		require.NoError(c.t, err)
+		url = ref.String()
+	}
 	req, err := http.NewRequest(method, url, nil)
 	require.NoError(c.t, err)
 
@@ -56,32 +111,58 @@ func (c *Mock) NewMockRequest(method, url string, params ...httpclient.RequestPa
 		opts = httpclient.NewRequestParameters(params...)
 	}
 
-	matcherName := fmt.Sprintf("%s_%s", c.t.Name(), url)
+	// Matchers are evaluated in the order of their names, so the sequence number preserves the registration order.
+	c.mu.Lock()
+	c.sequence++
+	matcherName := fmt.Sprintf("%s_%06d_%s", c.t.Name(), c.sequence, url)
+	c.mu.Unlock()
 	mReq := &MockRequest{
-		t:             c.t,
-		req:           req,
-		mockTransport: c.mock,
-		requestMatcher: httpmock.NewMatcher(matcherName, func(r *http.Request) bool {
-			return r.Method == method &&
-				r.URL.String() == url &&
-				(opts.Headers() == nil || assert.ObjectsAreEqual(opts.Headers(), r.Header))
-		}),
+		t:         c.t,
+		req:       req,
+		headers:   opts.Headers(),
+		mock:      c,
+		expected:  expectation{min: 1, max: -1},
 		responder: httpmock.NewStringResponder(http.StatusOK, "OK"),
 	}
+	mReq.requestMatcher = httpmock.NewMatcher(matcherName, func(r *http.Request) bool {
+		return len(mReq.mismatches(r)) == 0
+	})
 	return mReq
 }
 
 func (r *MockRequest) Register() {
-	r.mockTransport.RegisterMatcherResponder(
+	responder := r.responder
+	// The URL is matched by the request matcher, which supports path templates and unordered query parameters.
+	// Routes specific to the mock URL are not used, since httpmock only evaluates the matchers of the first
+	// route whose regular expression matches the request.
+	r.mock.mock.RegisterRegexpMatcherResponder(
 		r.req.Method,
-		r.req.URL.String(),
+		anyURL,
 		r.requestMatcher,
-		r.responder)
+		func(req *http.Request) (*http.Response, error) {
+			r.calls.Add(1)
+			return responder(req)
+		})
+	r.mock.register(r)
+}
+
+var anyURL = regexp.MustCompile(`.*`)
+
+// mismatches describes the differences between the request and the mock; the request matches if there are none.
+func (r *MockRequest) mismatches(req *http.Request) []mismatch {
+	var m []mismatch
+	if req.Method != r.req.Method {
+		m = append(m, mismatch{primary: true, reason: fmt.Sprintf("method: got %s, want %s", req.Method, r.req.Method)})
+	}
+	m = append(m, r.urlMismatches(req.URL)...)
+	m = append(m, r.headerMismatches(req.Header)...)
+	m = append(m, r.bodyMismatches(req)...)
+	return m
 }
 
 // String provides a representation of the mock request. Only used for debugging purposes.
 func (r *MockRequest) String() string {
-	return fmt.Sprintf("MockRequest: [%s] %s", r.req.Method, r.req.URL.String())
+	return fmt.Sprintf("MockRequest: [%s] %s", r.req.Method, displayURL(r.req.URL))
 }
 
 // Responder provides access to the current responder for inspection or direct operations.
@@ -105,26 +186,17 @@ func (r *MockRequest) RespondWithHeaders(respHeaders map[string]string) *MockReq
 	for k, v := range respHeaders {
 		h.Set(k, v)
 	}
-	r.responder.HeaderSet(h)
+	r.responder = r.responder.HeaderSet(h)
 	return r
 }
 
+// NewJSONMatcher creates an httpmock matcher for requests whose body is a JSON document equal to the given one.
+//
+// Deprecated: Use MockRequest.MatchBody with JSONBody, which also reports the differences.
 func (c *Mock) NewJSONMatcher(body string) httpmock.MatcherFunc {
-	c.t.Helper()
-
+	match := JSONBody(body)
 	return func(r *http.Request) bool {
-		var m1, m2 map[string]any
-		require.NoError(c.t, json.Unmarshal(interceptBody(c.t, r), &m1))
-		require.NoError(c.t, json.Unmarshal([]byte(body), &m2))
-		return assert.ObjectsAreEqual(m1, m2)
+		b, err := requestBody(r)
+		return err == nil && len(match(b, r.Header)) == 0
 	}
 }
-
-func interceptBody(t *testing.T, req *http.Request) []byte {
-	t.Helper()
-	body, err := io.ReadAll(req.Body)
-	require.NoError(t, err)
-	require.NoError(t, req.Body.Close())
-	req.Body = io.NopCloser(bytes.NewReader(body))
-	return body
-}
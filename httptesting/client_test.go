@@ -69,9 +69,80 @@ func TestClient_Head(t *testing.T) {
 	})
 }
 
+func TestMockRequest_RespondWithHeaders(t *testing.T) {
+	c := NewMock(t)
+	c.NewMockRequest(http.MethodGet, "http://localhost/p123").
+		RespondWithJSON(http.StatusOK, `{}`).
+		RespondWithHeaders(map[string]string{"X-Request-Id": "abc", "Content-Type": "application/problem+json"}).
+		RespondWithHeaders(map[string]string{"X-Request-Id": "def"}).
+		Register()
+	resp, err := c.Get(context.Background(), "http://localhost/p123")
+	require.NoError(t, err)
+	require.Equal(t, "def", resp.Header.Get("X-Request-Id"))
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
+
+func TestMock_TransportCallCountInfo(t *testing.T) {
+	c := NewMock(t)
+	r := c.NewMockRequest(http.MethodGet, "http://localhost/users/{id}")
+	r.Register()
+	_, err := c.Get(context.Background(), "http://localhost/users/42")
+	require.NoError(t, err)
+
+	matcher := " <" + t.Name() + "_000001_http://localhost/users/{id}>"
+	require.Equal(t, map[string]int{
+		"GET http://localhost/users/42" + matcher: 1,
+		"GET =~.*" + matcher:                      1,
+	}, c.Transport().GetCallCountInfo())
+	require.Equal(t, 1, c.Transport().GetTotalCallCount())
+	require.Equal(t, 1, r.Calls())
+}
+
 func TestClient_WithBaseURL(t *testing.T) {
 	c := NewMock(t)
 	_, err := c.WithBaseURL("http://www.example.com/test")
 	require.NoError(t, err)
 	require.Equal(t, "http://www.example.com/test", c.BaseURL())
 }
+
+func TestMock_RecordHooks(t *testing.T) {
+	c := NewMock(t)
+	c.AllowUnmatchedRequests()
+	requestURL := "http://localhost/p123"
+	c.NewMockRequest(http.MethodGet, requestURL).Register()
+	hooks := c.RecordHooks()
+
+	_, err := c.Get(context.Background(), requestURL)
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), "http://localhost/missing")
+	require.Error(t, err)
+
+	events := hooks.Events()
+	require.Len(t, events, 8)
+	require.Equal(t, []HookEvent{
+		{Scope: httpclient.PerCall, Kind: HookRequest, Method: http.MethodGet, URL: requestURL},
+		{Scope: httpclient.PerAttempt, Kind: HookRequest, Method: http.MethodGet, URL: requestURL},
+		{Scope: httpclient.PerAttempt, Kind: HookResponse, Method: http.MethodGet, URL: requestURL, StatusCode: http.StatusOK},
+		{Scope: httpclient.PerCall, Kind: HookResponse, Method: http.MethodGet, URL: requestURL, StatusCode: http.StatusOK},
+	}, events[:4])
+	require.Equal(t, HookError, events[6].Kind)
+	require.Equal(t, httpclient.PerAttempt, events[6].Scope)
+	require.Equal(t, HookError, events[7].Kind)
+	require.Equal(t, httpclient.PerCall, events[7].Scope)
+	require.Error(t, events[7].Err)
+
+	hooks.Reset()
+	require.Empty(t, hooks.Events())
+}
+
+func TestMock_UnmatchedRequestCurl(t *testing.T) {
+	c := NewMock(t)
+	c.AllowUnmatchedRequests()
+	c.NewMockRequest(http.MethodPost, "http://localhost/items").Never().Register()
+
+	_, err := c.Post(context.Background(), "http://localhost/items?dry_run=1", strings.NewReader(`{"name":"a"}`),
+		httpclient.WithHeaders(map[string]string{"Content-Type": "application/json"}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(),
+		`curl -X POST 'http://localhost/items?dry_run=1' -H 'Content-Type: application/json' --data-binary '{"name":"a"}'`)
+}
@@ -0,0 +1,123 @@
+package httptesting
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+)
+
+// headerMatcher is an additional condition on the values of a request header.
+type headerMatcher struct {
+	name string
+	// want describes the expected values, for mismatch reports.
+	want  string
+	match func(values []string) bool
+}
+
+// ExactHeaders requires the request headers to be equal to the headers of the MockRequest, instead of
+// including them. Additional header matchers still apply.
+func (r *MockRequest) ExactHeaders() *MockRequest {
+	r.exactHeaders = true
+	return r
+}
+
+// MatchHeaderRegexp requires a value of the header to match the regular expression.
+func (r *MockRequest) MatchHeaderRegexp(name, pattern string) *MockRequest {
+	r.t.Helper()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		r.t.Fatalf("httptesting: invalid header pattern %q: %v", pattern, err)
+	}
+	return r.addHeaderMatcher(name, fmt.Sprintf("value matching %q", pattern), func(values []string) bool {
+		return slices.ContainsFunc(values, re.MatchString)
+	})
+}
+
+// MatchHeaderFunc requires a value of the header to satisfy the predicate.
+func (r *MockRequest) MatchHeaderFunc(name string, predicate func(value string) bool) *MockRequest {
+	return r.addHeaderMatcher(name, "value satisfying the predicate", func(values []string) bool {
+		return slices.ContainsFunc(values, predicate)
+	})
+}
+
+// WithoutHeader requires the header to be absent from the request.
+func (r *MockRequest) WithoutHeader(name string) *MockRequest {
+	r.headerMatchers = append(r.headerMatchers, headerMatcher{
+		name:  http.CanonicalHeaderKey(name),
+		want:  "absent",
+		match: func(values []string) bool { return len(values) == 0 },
+	})
+	return r
+}
+
+func (r *MockRequest) addHeaderMatcher(name, want string, match func(values []string) bool) *MockRequest {
+	r.headerMatchers = append(r.headerMatchers, headerMatcher{
+		name: http.CanonicalHeaderKey(name),
+		want: want,
+		match: func(values []string) bool {
+			return len(values) > 0 && match(values)
+		},
+	})
+	return r
+}
+
+// headerMismatches describes the differences between the request headers and the expected ones.
+func (r *MockRequest) headerMismatches(got http.Header) []mismatch {
+	var m []mismatch
+	if r.exactHeaders {
+		m = exactHeaderMismatches(got, r.headers)
+	} else {
+		for _, name := range sortedHeaderNames(r.headers) {
+			m = append(m, valuesMismatch(name, got.Values(name), r.headers.Values(name))...)
+		}
+	}
+	for _, hm := range r.headerMatchers {
+		if values := got.Values(hm.name); !hm.match(values) {
+			if len(values) == 0 {
+				m = append(m, mismatch{reason: fmt.Sprintf("header %s: missing, want %s", hm.name, hm.want)})
+			} else {
+				m = append(m, mismatch{reason: fmt.Sprintf("header %s: got %q, want %s", hm.name, values, hm.want)})
+			}
+		}
+	}
+	return m
+}
+
+// exactHeaderMismatches describes the differences between the request headers and the expected ones,
+// including the unexpected headers.
+func exactHeaderMismatches(got, want http.Header) []mismatch {
+	names := sortedHeaderNames(got, want)
+	var m []mismatch
+	for _, name := range names {
+		if g := got.Values(name); len(want.Values(name)) == 0 {
+			m = append(m, mismatch{reason: fmt.Sprintf("header %s: unexpected %q", name, g)})
+			continue
+		}
+		m = append(m, valuesMismatch(name, got.Values(name), want.Values(name))...)
+	}
+	return m
+}
+
+func valuesMismatch(name string, got, want []string) []mismatch {
+	switch {
+	case len(got) == 0:
+		return []mismatch{{reason: fmt.Sprintf("header %s: missing, want %q", name, want)}}
+	case !slices.Equal(got, want):
+		return []mismatch{{reason: fmt.Sprintf("header %s: got %q, want %q", name, got, want)}}
+	default:
+		return nil
+	}
+}
+
+// sortedHeaderNames returns the canonical names of the headers in all the given sets.
+func sortedHeaderNames(headers ...http.Header) []string {
+	var names []string
+	for _, h := range headers {
+		for name := range h {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
@@ -0,0 +1,83 @@
+package httptesting
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+func TestMockRequest_HeaderMatching(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(r *MockRequest) *MockRequest
+		headers   map[string]string
+		reasons   []string
+	}{
+		{
+			name:      "subset",
+			configure: func(r *MockRequest) *MockRequest { return r },
+			headers:   map[string]string{"X-Tenant": "acme", "User-Agent": "sdk/1.0"},
+		},
+		{
+			name:      "missing",
+			configure: func(r *MockRequest) *MockRequest { return r },
+			headers:   map[string]string{"User-Agent": "sdk/1.0"},
+			reasons:   []string{`header X-Tenant: missing, want ["acme"]`},
+		},
+		{
+			name:      "exact",
+			configure: func(r *MockRequest) *MockRequest { return r.ExactHeaders() },
+			headers:   map[string]string{"X-Tenant": "acme", "User-Agent": "sdk/1.0"},
+			reasons:   []string{`header User-Agent: unexpected ["sdk/1.0"]`},
+		},
+		{
+			name: "regexp",
+			configure: func(r *MockRequest) *MockRequest {
+				return r.MatchHeaderRegexp("authorization", `^Bearer \w+$`)
+			},
+			headers: map[string]string{"X-Tenant": "acme", "Authorization": "Basic abc"},
+			reasons: []string{`header Authorization: got ["Basic abc"], want value matching "^Bearer \\w+$"`},
+		},
+		{
+			name: "predicate",
+			configure: func(r *MockRequest) *MockRequest {
+				return r.MatchHeaderFunc("X-Request-Id", func(v string) bool { return len(v) == 8 })
+			},
+			headers: map[string]string{"X-Tenant": "acme"},
+			reasons: []string{`header X-Request-Id: missing, want value satisfying the predicate`},
+		},
+		{
+			name:      "without",
+			configure: func(r *MockRequest) *MockRequest { return r.WithoutHeader("cookie") },
+			headers:   map[string]string{"X-Tenant": "acme", "Cookie": "session=1"},
+			reasons:   []string{`header Cookie: got ["session=1"], want absent`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &failureRecorder{TB: t}
+			m := NewMock(ft)
+			tt.configure(m.NewMockRequest(http.MethodGet, "http://localhost/items",
+				httpclient.WithHeaders(map[string]string{"X-Tenant": "acme"}))).
+				AtLeast(0).
+				Register()
+
+			_, err := m.Get(context.Background(), "http://localhost/items", httpclient.WithHeaders(tt.headers))
+			m.AssertExpectations()
+			if tt.reasons == nil {
+				require.NoError(t, err)
+				assert.Empty(t, ft.failures)
+				return
+			}
+			require.Error(t, err)
+			require.Len(t, ft.failures, 1)
+			assert.True(t, strings.HasSuffix(ft.failures[0], "\n  - "+strings.Join(tt.reasons, "\n  - ")), ft.failures[0])
+		})
+	}
+}
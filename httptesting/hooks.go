@@ -0,0 +1,86 @@
+package httptesting
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+// HookKind identifies the type of hook that produced a HookEvent.
+type HookKind string
+
+const (
+	// HookRequest events are recorded by request hooks, before the request is sent.
+	HookRequest HookKind = "request"
+	// HookResponse events are recorded by response hooks, when a response is received.
+	HookResponse HookKind = "response"
+	// HookError events are recorded by error hooks, when the request fails without a response.
+	HookError HookKind = "error"
+)
+
+// HookEvent is a hook invocation recorded by a HookRecorder.
+type HookEvent struct {
+	Scope   httpclient.HookScope
+	Kind    HookKind
+	Method  string
+	URL     string
+	Attempt int
+	// StatusCode is set for HookResponse events.
+	StatusCode int
+	// Err is set for HookError events.
+	Err error
+}
+
+// HookRecorder records the hook invocations of a Client, for use in tests.
+type HookRecorder struct {
+	mu     sync.Mutex
+	events []HookEvent
+}
+
+// RecordHooks registers hooks of every kind and scope on the Mock client and returns the HookRecorder
+// that keeps their invocations. Hooks registered later on the Mock are invoked after the recording ones.
+func (c *Mock) RecordHooks() *HookRecorder {
+	r := &HookRecorder{}
+	for _, scope := range []httpclient.HookScope{httpclient.PerCall, httpclient.PerAttempt} {
+		c.OnRequest(scope, func(req *http.Request) error {
+			r.record(scope, HookRequest, req, 0, nil)
+			return nil
+		})
+		c.OnResponse(scope, func(req *http.Request, resp *http.Response) {
+			r.record(scope, HookResponse, req, resp.StatusCode, nil)
+		})
+		c.OnError(scope, func(req *http.Request, err error) {
+			r.record(scope, HookError, req, 0, err)
+		})
+	}
+	return r
+}
+
+func (r *HookRecorder) record(scope httpclient.HookScope, kind HookKind, req *http.Request, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, HookEvent{
+		Scope:      scope,
+		Kind:       kind,
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		Attempt:    httpclient.Attempt(req),
+		StatusCode: status,
+		Err:        err,
+	})
+}
+
+// Events returns the recorded hook invocations in the order they occurred.
+func (r *HookRecorder) Events() []HookEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]HookEvent(nil), r.events...)
+}
+
+// Reset discards the recorded hook invocations.
+func (r *HookRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
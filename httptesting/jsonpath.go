@@ -0,0 +1,116 @@
+package httptesting
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep selects an object field by name, an array element by index, or all the children if wildcard is set.
+type jsonPathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+type jsonPath []jsonPathStep
+
+func parseJSONPath(expr string) (jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("invalid JSONPath %q: must start with $", expr)
+	}
+	var path jsonPath
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty field name", expr)
+			}
+			path = append(path, jsonPathStep{name: name, wildcard: name == "*"})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unterminated [", expr)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case selector == "*":
+				path = append(path, jsonPathStep{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				path = append(path, jsonPathStep{name: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: invalid selector [%s]", expr, selector)
+				}
+				path = append(path, jsonPathStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest)
+		}
+	}
+	return path, nil
+}
+
+// evaluate returns the selected value, or the array of the selected values if the path has wildcards.
+func (p jsonPath) evaluate(doc any) (any, bool) {
+	values := []any{doc}
+	wildcard := false
+	for _, step := range p {
+		var next []any
+		for _, v := range values {
+			next = append(next, step.apply(v)...)
+		}
+		values = next
+		wildcard = wildcard || step.wildcard
+	}
+	if wildcard {
+		if values == nil {
+			values = []any{}
+		}
+		return values, true
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+func (s jsonPathStep) apply(v any) []any {
+	switch node := v.(type) {
+	case map[string]any:
+		if s.wildcard {
+			keys := sortedJSONKeys(node)
+			children := make([]any, len(keys))
+			for i, k := range keys {
+				children[i] = node[k]
+			}
+			return children
+		}
+		if child, ok := node[s.name]; ok && !s.isIndex {
+			return []any{child}
+		}
+	case []any:
+		if s.wildcard {
+			return node
+		}
+		index := s.index
+		if index < 0 {
+			index += len(node)
+		}
+		if s.isIndex && index >= 0 && index < len(node) {
+			return []any{node[index]}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package httptesting
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+// ServerMock serves the MockRequest registrations from a local httptest.Server, for code that must use
+// a real connection, e.g. custom dialers, TLS configuration or HTTP/2. Its Client uses the transport
+// of the server and is configured with the server URL as base URL, so relative URLs can be used
+// for both requests and registrations. Requests that match no registration receive a 501 Not Implemented response
+// whose body contains the curl equivalent of the request, and fail the test like with a Mock.
+type ServerMock struct {
+	*Mock
+	Server *httptest.Server
+}
+
+// NewServerMock creates a ServerMock backed by a plain HTTP server. The server is closed at the end of the test.
+func NewServerMock(t testing.TB) *ServerMock {
+	return newServerMock(t, false)
+}
+
+// NewTLSServerMock creates a ServerMock backed by an HTTPS server with HTTP/2 enabled.
+// The Client trusts the certificate of the server. The server is closed at the end of the test.
+func NewTLSServerMock(t testing.TB) *ServerMock {
+	return newServerMock(t, true)
+}
+
+func newServerMock(t testing.TB, tls bool) *ServerMock {
+	m := newMock(t)
+	rt := m.transport()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveMock(rt, w, r)
+	}))
+	if tls {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Client = httpclient.NewWithTransport(srv.Client().Transport)
+	if _, err := m.WithBaseURL(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	m.baseURL = base
+	return &ServerMock{Mock: m, Server: srv}
+}
+
+// URL returns the absolute URL of the given path on the server.
+func (m *ServerMock) URL(path string) string {
+	return m.Server.URL + path
+}
+
+// serveMock responds with the registered mock that matches the request, as it was sent by the client.
+func serveMock(rt http.RoundTripper, w http.ResponseWriter, r *http.Request) {
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	req.URL.Host = r.Host
+	req.URL.Scheme = "http"
+	if r.TLS != nil {
+		req.URL.Scheme = "https"
+	}
+	// Remove the headers added by the client transport, which are not part of the request built by the caller.
+	if req.Header.Get("Accept-Encoding") == "gzip" {
+		req.Header.Del("Accept-Encoding")
+	}
+	if strings.HasPrefix(req.Header.Get("User-Agent"), "Go-http-client/") {
+		req.Header.Del("User-Agent")
+	}
+	if r.ContentLength == 0 {
+		req.Body = nil
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
@@ -0,0 +1,47 @@
+package httptesting
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+func TestServerMock(t *testing.T) {
+	tests := []struct {
+		name    string
+		newMock func(testing.TB) *ServerMock
+		proto   string
+	}{
+		{name: "plain", newMock: NewServerMock, proto: "HTTP/1.1"},
+		{name: "TLS", newMock: NewTLSServerMock, proto: "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tt.newMock(t)
+			m.AllowUnmatchedRequests()
+			m.NewMockRequest(http.MethodGet, "/users/1?expand=true",
+				httpclient.WithHeaders(map[string]string{"X-Tenant": "acme"})).
+				RespondWithJSON(http.StatusOK, `{"id":1}`).
+				RespondWithHeaders(map[string]string{"X-Request-Id": "abc"}).
+				Register()
+
+			resp, err := m.Get(context.Background(), "/users/1?expand=true",
+				httpclient.WithHeaders(map[string]string{"X-Tenant": "acme"}))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "abc", resp.Header.Get("X-Request-Id"))
+			assert.JSONEq(t, `{"id":1}`, string(httpclient.MustInterceptResponseBody(resp)))
+			assert.Equal(t, tt.proto, resp.Proto)
+
+			resp, err = m.Get(context.Background(), m.URL("/users/2"))
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+			assert.Contains(t, string(httpclient.MustInterceptResponseBody(resp)), "curl '"+m.URL("/users/2")+"'")
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package httptesting
+
+import (
+	"context"
+	"sync"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+// InMemoryExporter is an httpclient.SpanExporter that keeps the finished spans in memory, for use in tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []httpclient.Span
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(_ context.Context, span httpclient.Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans in the order they finished.
+func (e *InMemoryExporter) Spans() []httpclient.Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]httpclient.Span(nil), e.spans...)
+}
+
+// Reset discards the exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -0,0 +1,107 @@
+package httptesting
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// AnyValue is the query parameter value of a MockRequest URL that matches any value of the parameter,
+// e.g. `/items?page=*`. The parameter must still be present.
+const AnyValue = "*"
+
+// PartialQuery allows requests to have query parameters that are not part of the MockRequest URL.
+func (r *MockRequest) PartialQuery() *MockRequest {
+	r.partialQuery = true
+	return r
+}
+
+// urlMismatches describes the differences between the request URL and the MockRequest URL.
+// Path segments of the form `{name}` match any single segment, and query parameters match regardless of the order
+// of the parameters and of their values.
+func (r *MockRequest) urlMismatches(got *url.URL) []mismatch {
+	want := r.req.URL
+	if !strings.EqualFold(got.Scheme, want.Scheme) || !strings.EqualFold(got.Host, want.Host) || !matchPath(got.EscapedPath(), want.EscapedPath()) {
+		return []mismatch{{primary: true, reason: fmt.Sprintf("url: got %s, want %s", displayURL(got), displayURL(want))}}
+	}
+	gotQuery, wantQuery := got.Query(), want.Query()
+	var m []mismatch
+	for _, name := range sortedKeys(gotQuery, wantQuery) {
+		g, w := gotQuery[name], wantQuery[name]
+		switch {
+		case len(w) == 0:
+			if !r.partialQuery {
+				m = append(m, mismatch{reason: fmt.Sprintf("query %s: unexpected %q", name, g)})
+			}
+		case len(g) == 0:
+			m = append(m, mismatch{reason: fmt.Sprintf("query %s: missing, want %q", name, w)})
+		case !matchQueryValues(g, w):
+			m = append(m, mismatch{reason: fmt.Sprintf("query %s: got %q, want %q", name, g, w)})
+		}
+	}
+	return m
+}
+
+// matchPath matches the escaped request path against the escaped template path. The paths are split into segments
+// before unescaping them, so that an escaped slash, e.g. `a%2Fb`, remains part of a single segment.
+func matchPath(got, template string) bool {
+	gotSegments, templateSegments := pathSegments(got), pathSegments(template)
+	if len(gotSegments) != len(templateSegments) {
+		return false
+	}
+	for i, t := range templateSegments {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if gotSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if gotSegments[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+func pathSegments(escapedPath string) []string {
+	segments := strings.Split(escapedPath, "/")
+	for i, s := range segments {
+		if unescaped, err := url.PathUnescape(s); err == nil {
+			segments[i] = unescaped
+		}
+	}
+	return segments
+}
+
+func matchQueryValues(got, want []string) bool {
+	if len(want) == 1 && want[0] == AnyValue {
+		return true
+	}
+	return slices.Equal(slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(want)))
+}
+
+// displayURL formats the URL with the path as it was written, so that path templates remain readable
+// and escaped slashes remain visible.
+func displayURL(u *url.URL) string {
+	path := u.Path
+	if u.RawPath != "" {
+		path = u.RawPath
+	}
+	s := u.Scheme + "://" + u.Host + path
+	if u.RawQuery != "" {
+		s += "?" + u.RawQuery
+	}
+	return s
+}
+
+func sortedKeys(values ...url.Values) []string {
+	var keys []string
+	for _, v := range values {
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
@@ -0,0 +1,118 @@
+package httptesting
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockRequest_URLMatching(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockURL   string
+		partial   bool
+		requested string
+		reasons   []string
+	}{
+		{
+			name:      "query order",
+			mockURL:   "http://localhost/items?b=2&a=1&tag=x&tag=y",
+			requested: "http://localhost/items?a=1&tag=y&b=2&tag=x",
+		},
+		{
+			name:      "query encoding",
+			mockURL:   "http://localhost/items?q=a b",
+			requested: "http://localhost/items?q=a%20b",
+		},
+		{
+			name:      "unexpected query parameter",
+			mockURL:   "http://localhost/items?a=1",
+			requested: "http://localhost/items?a=1&debug=true",
+			reasons:   []string{`query debug: unexpected ["true"]`},
+		},
+		{
+			name:      "partial query",
+			mockURL:   "http://localhost/items?a=1",
+			partial:   true,
+			requested: "http://localhost/items?a=1&debug=true",
+		},
+		{
+			name:      "partial query mismatch",
+			mockURL:   "http://localhost/items?a=1&b=2",
+			partial:   true,
+			requested: "http://localhost/items?a=2&debug=true",
+			reasons:   []string{`query a: got ["2"], want ["1"]`, `query b: missing, want ["2"]`},
+		},
+		{
+			name:      "wildcard",
+			mockURL:   "http://localhost/items?page=*",
+			requested: "http://localhost/items?page=7",
+		},
+		{
+			name:      "wildcard requires the parameter",
+			mockURL:   "http://localhost/items?page=*",
+			requested: "http://localhost/items",
+			reasons:   []string{`query page: missing, want ["*"]`},
+		},
+		{
+			name:      "path template",
+			mockURL:   "http://localhost/users/{id}/posts/{post}",
+			requested: "http://localhost/users/42/posts/7",
+		},
+		{
+			name:      "path template segments",
+			mockURL:   "http://localhost/users/{id}",
+			requested: "http://localhost/users/42/posts",
+			reasons:   []string{`url: got http://localhost/users/42/posts, want http://localhost/users/{id}`},
+		},
+		{
+			name:      "path template escaped slash",
+			mockURL:   "http://localhost/users/{id}",
+			requested: "http://localhost/users/a%2Fb",
+		},
+		{
+			name:      "escaped slash is a single segment",
+			mockURL:   "http://localhost/users/a/b",
+			requested: "http://localhost/users/a%2Fb",
+			reasons:   []string{`url: got http://localhost/users/a%2Fb, want http://localhost/users/a/b`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &failureRecorder{TB: t}
+			m := NewMock(ft)
+			r := m.NewMockRequest(http.MethodGet, tt.mockURL).AtLeast(0)
+			if tt.partial {
+				r.PartialQuery()
+			}
+			r.Register()
+
+			_, err := m.Get(context.Background(), tt.requested)
+			m.AssertExpectations()
+			if tt.reasons == nil {
+				require.NoError(t, err)
+				assert.Equal(t, 1, r.Calls())
+				assert.Empty(t, ft.failures)
+				return
+			}
+			require.Error(t, err)
+			require.Len(t, ft.failures, 1)
+			assert.True(t, strings.HasSuffix(ft.failures[0], "\n  - "+strings.Join(tt.reasons, "\n  - ")), ft.failures[0])
+		})
+	}
+}
+
+func TestMockRequest_RegistrationOrder(t *testing.T) {
+	m := NewMock(t)
+	m.NewMockRequest(http.MethodGet, "http://localhost/users/me").Once().Register()
+	m.NewMockRequest(http.MethodGet, "http://localhost/users/{id}").Once().Register()
+
+	_, err := m.Get(context.Background(), "http://localhost/users/me")
+	require.NoError(t, err)
+	_, err = m.Get(context.Background(), "http://localhost/users/42")
+	require.NoError(t, err)
+}
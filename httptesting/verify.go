@@ -0,0 +1,171 @@
+package httptesting
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+// expectation is the allowed number of calls of a MockRequest; a negative max means unlimited.
+type expectation struct {
+	min, max int
+}
+
+func (e expectation) met(calls int) bool {
+	return calls >= e.min && (e.max < 0 || calls <= e.max)
+}
+
+func (e expectation) String() string {
+	switch {
+	case e.max < 0:
+		return fmt.Sprintf("at least %d", e.min)
+	case e.min == e.max:
+		return fmt.Sprintf("exactly %d", e.min)
+	default:
+		return fmt.Sprintf("between %d and %d", e.min, e.max)
+	}
+}
+
+// Times expects the mock to be called exactly n times.
+func (r *MockRequest) Times(n int) *MockRequest {
+	r.expected = expectation{min: n, max: n}
+	return r
+}
+
+// AtLeast expects the mock to be called at least n times.
+func (r *MockRequest) AtLeast(n int) *MockRequest {
+	r.expected = expectation{min: n, max: -1}
+	return r
+}
+
+// Once expects the mock to be called exactly once.
+func (r *MockRequest) Once() *MockRequest {
+	return r.Times(1)
+}
+
+// Never expects the mock not to be called.
+func (r *MockRequest) Never() *MockRequest {
+	return r.Times(0)
+}
+
+// Calls returns the number of requests that matched the mock.
+func (r *MockRequest) Calls() int {
+	return int(r.calls.Load())
+}
+
+// AllowUnmatchedRequests disables the failure of the test for requests that match no registered mock.
+func (c *Mock) AllowUnmatchedRequests() *Mock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.allowUnmatched = true
+	return c
+}
+
+// AssertExpectations fails the test for each registered mock that was not called the expected number of times,
+// and for each request that matched no registered mock, along with its differences from the closest mock.
+// It is invoked automatically when the test completes.
+func (c *Mock) AssertExpectations() {
+	c.t.Helper()
+
+	c.mu.Lock()
+	registered := append([]*MockRequest(nil), c.registered...)
+	unmatched := append([]*unmatchedRequest(nil), c.unmatched...)
+	allowUnmatched := c.allowUnmatched
+	c.mu.Unlock()
+
+	for _, r := range registered {
+		if calls := r.Calls(); !r.expected.met(calls) {
+			c.t.Errorf("httptesting: %s expected to be called %s times, called %d times", r, r.expected, calls)
+		}
+	}
+	if allowUnmatched {
+		return
+	}
+	for _, u := range unmatched {
+		msg := fmt.Sprintf("httptesting: unexpected request %s %s", u.req.Method, u.req.URL)
+		if u.curl != "" {
+			msg += "\n" + u.curl
+		}
+		if closest, reasons := closestMock(registered, u.req); closest != nil {
+			msg += fmt.Sprintf("\nclosest mock %s:\n  - %s", closest, strings.Join(reasons, "\n  - "))
+		}
+		c.t.Errorf("%s", msg)
+	}
+}
+
+func (c *Mock) register(r *MockRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registered = append(c.registered, r)
+}
+
+// unmatchedRequest is a copy of a request that matched no registered mock, with a replayable body.
+type unmatchedRequest struct {
+	req  *http.Request
+	curl string
+}
+
+func (c *Mock) recordUnmatched(req *http.Request) *unmatchedRequest {
+	u := &unmatchedRequest{req: req.Clone(req.Context())}
+	if body, err := requestBody(req); err == nil && body != nil {
+		u.req.Body = io.NopCloser(bytes.NewReader(body))
+		u.req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if curl, err := httpclient.ToCurl(u.req); err == nil {
+		u.curl = curl
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unmatched = append(c.unmatched, u)
+	return u
+}
+
+// mismatch is a difference between a request and a MockRequest. Primary mismatches, i.e. of the method or the URL,
+// weigh more when looking for the closest mock.
+type mismatch struct {
+	primary bool
+	reason  string
+}
+
+// closestMock returns the registered mock with the fewest primary differences from the request,
+// and then the fewest differences overall.
+func closestMock(registered []*MockRequest, req *http.Request) (*MockRequest, []string) {
+	var closest *MockRequest
+	var closestMismatches []mismatch
+	for _, r := range registered {
+		if req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+		m := r.mismatches(req)
+		if closest == nil || closer(m, closestMismatches) {
+			closest, closestMismatches = r, m
+		}
+	}
+	reasons := make([]string, len(closestMismatches))
+	for i, m := range closestMismatches {
+		reasons[i] = m.reason
+	}
+	return closest, reasons
+}
+
+func closer(a, b []mismatch) bool {
+	primary := func(m []mismatch) int {
+		n := 0
+		for _, mm := range m {
+			if mm.primary {
+				n++
+			}
+		}
+		return n
+	}
+	if pa, pb := primary(a), primary(b); pa != pb {
+		return pa < pb
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,60 @@
+package httptesting
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+)
+
+func TestMock_AssertExpectations(t *testing.T) {
+	ft := &failureRecorder{TB: t}
+	m := NewMock(ft)
+	once := m.NewMockRequest(http.MethodGet, "http://localhost/once").Once()
+	once.Register()
+	m.NewMockRequest(http.MethodGet, "http://localhost/twice").Times(2).Register()
+	m.NewMockRequest(http.MethodGet, "http://localhost/never").Never().Register()
+	m.NewMockRequest(http.MethodGet, "http://localhost/at-least").AtLeast(2).Register()
+	m.NewMockRequest(http.MethodGet, "http://localhost/default").Register()
+
+	for _, path := range []string{"/once", "/once", "/twice", "/twice", "/at-least", "/at-least", "/at-least"} {
+		_, err := m.Get(context.Background(), "http://localhost"+path)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, once.Calls())
+
+	m.AssertExpectations()
+	assert.Equal(t, []string{
+		"httptesting: MockRequest: [GET] http://localhost/once expected to be called exactly 1 times, called 2 times",
+		"httptesting: MockRequest: [GET] http://localhost/default expected to be called at least 1 times, called 0 times",
+	}, ft.failures)
+}
+
+func TestMock_AssertExpectations_Unmatched(t *testing.T) {
+	ft := &failureRecorder{TB: t}
+	m := NewMock(ft)
+	m.NewMockRequest(http.MethodGet, "http://localhost/users").Never().Register()
+	m.NewMockRequest(http.MethodPost, "http://localhost/users",
+		httpclient.WithHeaders(map[string]string{"Content-Type": "application/json", "X-Tenant": "acme"})).
+		Never().
+		Register()
+
+	_, err := m.Post(context.Background(), "http://localhost/users", nil,
+		httpclient.WithHeaders(map[string]string{"Content-Type": "application/json", "X-Tenant": "other", "X-Debug": "1"}))
+	require.Error(t, err)
+
+	m.AssertExpectations()
+	require.Len(t, ft.failures, 1)
+	assert.Equal(t, `httptesting: unexpected request POST http://localhost/users
+curl -X POST 'http://localhost/users' -H 'Content-Type: application/json' -H 'X-Debug: 1' -H 'X-Tenant: other'
+closest mock MockRequest: [POST] http://localhost/users:
+  - header X-Tenant: got ["other"], want ["acme"]`, ft.failures[0])
+
+	ft.failures = nil
+	m.AllowUnmatchedRequests().AssertExpectations()
+	assert.Empty(t, ft.failures)
+}
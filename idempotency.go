@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header that carries the idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKeyGenerator returns a new unique idempotency key.
+type IdempotencyKeyGenerator func() (string, error)
+
+// WithIdempotencyKey sets the idempotency key of the request explicitly, e.g. when the logical operation
+// is retried by the caller across multiple calls. It takes precedence over the Client IdempotencyKeyGenerator.
+func WithIdempotencyKey(key string) RequestParameter {
+	return func(opts *RequestParameters) {
+		opts.idempotencyKey = key
+	}
+}
+
+// IdempotencyKeyFromResponse returns the idempotency key the request of the response was sent with.
+func IdempotencyKeyFromResponse(resp *http.Response) string {
+	if resp == nil || resp.Request == nil {
+		return ""
+	}
+	return resp.Request.Header.Get(IdempotencyKeyHeader)
+}
+
+// IdempotencyKeyFromError returns the idempotency key of the request that failed with the given error.
+func IdempotencyKeyFromError(err error) string {
+	var keyErr *idempotencyKeyError
+	if errors.As(err, &keyErr) {
+		return keyErr.key
+	}
+	return ""
+}
+
+// idempotencyKeyError annotates an error with the idempotency key of the failed request.
+// The error message is unchanged, and errors.As and errors.Is see through to the original error, e.g. a *url.Error.
+type idempotencyKeyError struct {
+	key string
+	err error
+}
+
+func (e *idempotencyKeyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *idempotencyKeyError) Unwrap() error {
+	return e.err
+}
+
+// requiresIdempotencyKey reports whether the method is neither safe nor idempotent, see RFC 9110 Section 9.2.
+func requiresIdempotencyKey(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch
+}
+
+// setIdempotencyKey adds an idempotency key to POST and PATCH requests that do not already carry one.
+// The key is generated once per logical call, so every attempt made for the call sends the same key.
+func setIdempotencyKey(req *http.Request, generator IdempotencyKeyGenerator) error {
+	if !requiresIdempotencyKey(req.Method) || req.Header.Get(IdempotencyKeyHeader) != "" {
+		return nil
+	}
+	key := requestParametersFromContext(req.Context()).idempotencyKey
+	if key == "" {
+		if generator == nil {
+			return nil
+		}
+		var err error
+		if key, err = generator(); err != nil {
+			return newBaseError(fmt.Errorf("failed to generate idempotency key: %w", err))
+		}
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return nil
+}
+
+// UUIDv4 generates a random UUID (version 4), see RFC 9562 Section 5.4.
+func UUIDv4() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(u, 4), nil
+}
+
+// UUIDv7 generates a time-ordered UUID (version 7), see RFC 9562 Section 5.7.
+// Keys generated by UUIDv7 sort by creation time, which benefits the indexes of the server.
+func UUIDv7() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ts[2:])
+	return formatUUID(u, 7), nil
+}
+
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithIdempotencyKeys(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	var mu sync.Mutex
+	var keys []string
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		keys = append(keys, req.Header.Get(IdempotencyKeyHeader))
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		return httpmock.NewStringResponse(http.StatusCreated, ""), nil
+	})
+	c := NewWithTransport(mt).WithIdempotencyKeys(UUIDv7)
+	ctx := context.Background()
+
+	// Every attempt of a hedged call carries the same key.
+	resp, err := c.Post(ctx, "https://api.example.com/payments", strings.NewReader("{}"),
+		WithHedging(HedgePolicy{Delay: time.Millisecond, AllowUnsafeMethods: true}))
+	require.NoError(t, err)
+	mu.Lock()
+	sent := append([]string(nil), keys...)
+	mu.Unlock()
+	require.Len(t, sent, 2)
+	assert.NotEmpty(t, sent[0])
+	assert.Equal(t, sent[0], sent[1])
+	assert.Equal(t, sent[0], IdempotencyKeyFromResponse(resp))
+
+	// Every logical call has a new key.
+	resp, err = c.Post(ctx, "https://api.example.com/payments", strings.NewReader("{}"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sent[0], IdempotencyKeyFromResponse(resp))
+
+	resp, err = c.Post(ctx, "https://api.example.com/payments", strings.NewReader("{}"), WithIdempotencyKey("order-42"))
+	require.NoError(t, err)
+	assert.Equal(t, "order-42", IdempotencyKeyFromResponse(resp))
+
+	resp, err = c.Get(ctx, "https://api.example.com/payments")
+	require.NoError(t, err)
+	assert.Empty(t, IdempotencyKeyFromResponse(resp))
+}
+
+func TestClient_WithIdempotencyKeys_Errors(t *testing.T) {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(httpmock.NewErrorResponder(errors.New("connection reset")))
+	c := NewWithTransport(mt).WithIdempotencyKeys(func() (string, error) {
+		return "custom-key", nil
+	})
+
+	_, err := c.Post(context.Background(), "https://api.example.com/payments", strings.NewReader("{}"))
+	require.Error(t, err)
+	assert.Equal(t, "custom-key", IdempotencyKeyFromError(err))
+	assert.Contains(t, err.Error(), "connection reset")
+	// The annotated error still unwraps to the errors returned by net/http and by the middlewares.
+	var urlErr *url.Error
+	require.ErrorAs(t, err, &urlErr)
+	assert.Equal(t, "https://api.example.com/payments", urlErr.URL)
+	assert.EqualError(t, urlErr.Err, "connection reset")
+
+	c.WithRateLimiter(NewRateLimiter(0.001, 1).WithoutWait())
+	_, _ = c.Post(context.Background(), "https://api.example.com/payments", strings.NewReader("{}"))
+	_, err = c.Post(context.Background(), "https://api.example.com/payments", strings.NewReader("{}"))
+	var baseErr *BaseError
+	require.ErrorAs(t, err, &baseErr)
+	assert.True(t, HasErrorTag(err, ErrorTagRateLimited))
+	assert.Equal(t, "custom-key", IdempotencyKeyFromError(err))
+
+	c.WithIdempotencyKeys(func() (string, error) {
+		return "", errors.New("entropy exhausted")
+	})
+	_, err = c.Post(context.Background(), "https://api.example.com/payments", strings.NewReader("{}"))
+	require.ErrorContains(t, err, "failed to generate idempotency key: entropy exhausted")
+	assert.Empty(t, IdempotencyKeyFromError(err))
+}
+
+func TestUUID(t *testing.T) {
+	v4, err := UUIDv4()
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), v4)
+
+	before := time.Now().UnixMilli()
+	v7, err := UUIDv7()
+	require.NoError(t, err)
+	after := time.Now().UnixMilli()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), v7)
+	ts, err := strconv.ParseInt(strings.ReplaceAll(v7[:13], "-", ""), 16, 64)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, ts, before)
+	assert.LessOrEqual(t, ts, after)
+}
@@ -0,0 +1,78 @@
+// Package conditional implements the conditional request and 304 Not Modified handling
+// shared by the Revalidator and the httpcache package.
+package conditional
+
+import (
+	"net/http"
+	"strings"
+)
+
+// conditionalHeaders are the request header fields that make a request conditional, see RFC 9110 Section 13.1.
+var conditionalHeaders = []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"}
+
+// notModifiedExcluded are the header fields of a 304 Not Modified response that do not replace
+// the stored ones, because they describe the transfer of the stored content, see RFC 9111 Section 3.2.
+var notModifiedExcluded = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+	"Content-Range":     true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+}
+
+// IsRequest reports whether the request carries any conditional header field, e.g. If-None-Match.
+func IsRequest(req *http.Request) bool {
+	for _, name := range conditionalHeaders {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Request returns a copy of the request with the If-None-Match and If-Modified-Since header fields set
+// from the ETag and Last-Modified validators of a stored response. The request is returned unchanged
+// if the stored response has no validators.
+func Request(req *http.Request, stored http.Header) *http.Request {
+	etag, lastModified := stored.Get("ETag"), stored.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+	r := req.Clone(req.Context())
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		r.Header.Set("If-Modified-Since", lastModified)
+	}
+	return r
+}
+
+// UpdateHeader returns a copy of the header of a stored response, updated with the header fields
+// of a 304 Not Modified response, see RFC 9111 Section 3.2.
+func UpdateHeader(stored, notModified http.Header) http.Header {
+	header := stored.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for name, values := range notModified {
+		if !notModifiedExcluded[http.CanonicalHeaderKey(name)] {
+			header[name] = values
+		}
+	}
+	return header
+}
+
+// VaryFields returns the canonical names of the request header fields nominated by the Vary header.
+func VaryFields(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
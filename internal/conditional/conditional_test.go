@@ -0,0 +1,47 @@
+package conditional
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.com/items/1", nil)
+	require.NoError(t, err)
+	assert.False(t, IsRequest(req))
+
+	assert.Same(t, req, Request(req, http.Header{"Content-Type": {"application/json"}}))
+
+	conditional := Request(req, http.Header{
+		"Etag":          {`"v1"`},
+		"Last-Modified": {"Wed, 01 May 2024 12:00:00 GMT"},
+	})
+	assert.True(t, IsRequest(conditional))
+	assert.Equal(t, `"v1"`, conditional.Header.Get("If-None-Match"))
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", conditional.Header.Get("If-Modified-Since"))
+	assert.False(t, IsRequest(req))
+}
+
+func TestUpdateHeader(t *testing.T) {
+	stored := http.Header{
+		"Content-Length": {"42"},
+		"Cache-Control":  {"max-age=10"},
+		"Etag":           {`"v1"`},
+	}
+
+	updated := UpdateHeader(stored, http.Header{
+		"Content-Length": {"0"},
+		"Cache-Control":  {"max-age=30"},
+		"Connection":     {"close"},
+	})
+
+	assert.Equal(t, http.Header{
+		"Content-Length": {"42"},
+		"Cache-Control":  {"max-age=30"},
+		"Etag":           {`"v1"`},
+	}, updated)
+	assert.Equal(t, "max-age=10", stored.Get("Cache-Control"))
+}
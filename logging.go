@@ -0,0 +1,228 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// RedactedValue replaces the values of sensitive headers and query parameters in logs.
+const RedactedValue = "REDACTED"
+
+// DefaultRedactedHeaders are the headers whose values are never logged by a RequestLogger.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// DefaultRedactedQueryParameters are the query parameters whose values are never logged by a RequestLogger.
+var DefaultRedactedQueryParameters = []string{"access_token"}
+
+// RequestLogger logs every request and its outcome through a slog.Logger. Each record has the
+// `method`, `url`, `status`, `duration`, `request_bytes` and `response_bytes` attributes,
+// or the `error` attribute when no response was received. Byte counts are taken from Content-Length
+// and are -1 when unknown.
+//
+// Headers and bodies are optionally logged as well. The values of sensitive headers and query parameters
+// are replaced with RedactedValue.
+type RequestLogger struct {
+	logger      *slog.Logger
+	level       func(resp *http.Response, err error) slog.Level
+	logHeaders  bool
+	maxBodySize int
+	redactor    *redactor
+	now         func() time.Time
+}
+
+// NewRequestLogger creates a RequestLogger that writes to the given slog.Logger, or to slog.Default() if nil.
+func NewRequestLogger(logger *slog.Logger) *RequestLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RequestLogger{
+		logger:   logger,
+		level:    DefaultLogLevel,
+		redactor: newRedactor(),
+		now:      time.Now,
+	}
+}
+
+// DefaultLogLevel logs successful and redirection responses at the Info level, client errors at the Warn level,
+// and server errors as well as transport errors at the Error level.
+func DefaultLogLevel(resp *http.Response, err error) slog.Level {
+	switch {
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		return slog.LevelError
+	case resp.StatusCode >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithLevel logs every request at the given level, regardless of the outcome.
+func (l *RequestLogger) WithLevel(level slog.Level) *RequestLogger {
+	return l.WithLevelFunc(func(*http.Response, error) slog.Level {
+		return level
+	})
+}
+
+// WithLevelFunc selects the level of each record based on the outcome of the request.
+// Exactly one of `resp` and `err` is non-nil.
+func (l *RequestLogger) WithLevelFunc(level func(resp *http.Response, err error) slog.Level) *RequestLogger {
+	l.level = level
+	return l
+}
+
+// WithHeaderLogging logs the request and response headers, under the `request_headers` and `response_headers` groups.
+func (l *RequestLogger) WithHeaderLogging() *RequestLogger {
+	l.logHeaders = true
+	return l
+}
+
+// WithBodyLogging logs up to `maxSize` bytes of the request and response bodies, under the `request_body`
+// and `response_body` attributes. Only replayable request bodies are logged. The response body is not
+// consumed; the logged prefix is still available to the caller.
+func (l *RequestLogger) WithBodyLogging(maxSize int) *RequestLogger {
+	l.maxBodySize = maxSize
+	return l
+}
+
+// WithRedactedHeaders adds to the headers whose values are redacted.
+func (l *RequestLogger) WithRedactedHeaders(names ...string) *RequestLogger {
+	l.redactor.addHeaders(names...)
+	return l
+}
+
+// WithRedactedQueryParameters adds to the query parameters whose values are redacted.
+func (l *RequestLogger) WithRedactedQueryParameters(names ...string) *RequestLogger {
+	l.redactor.addQueryParameters(names...)
+	return l
+}
+
+// Middleware returns the Middleware that logs requests.
+func (l *RequestLogger) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := l.now()
+			resp, err := next.RoundTrip(req)
+			duration := l.now().Sub(start)
+
+			ctx := req.Context()
+			level := l.level(resp, err)
+			if !l.logger.Enabled(ctx, level) {
+				return resp, err
+			}
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("url", l.logURL(req.URL)),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.Duration("duration", duration), slog.String("error", err.Error()))
+			} else {
+				requestBytes := req.ContentLength
+				if req.Body == nil || req.Body == http.NoBody {
+					requestBytes = 0
+				}
+				attrs = append(attrs,
+					slog.Int("status", resp.StatusCode),
+					slog.Duration("duration", duration),
+					slog.Int64("request_bytes", requestBytes),
+					slog.Int64("response_bytes", resp.ContentLength))
+			}
+			if l.logHeaders {
+				attrs = append(attrs, l.headersAttr("request_headers", req.Header))
+				if resp != nil {
+					attrs = append(attrs, l.headersAttr("response_headers", resp.Header))
+				}
+			}
+			if l.maxBodySize > 0 {
+				if body, ok := l.requestBody(req); ok {
+					attrs = append(attrs, slog.String("request_body", body))
+				}
+				if resp != nil {
+					attrs = append(attrs, slog.String("response_body", l.responseBody(resp)))
+				}
+			}
+			msg := "http request"
+			if err != nil {
+				msg = "http request failed"
+			}
+			l.logger.LogAttrs(ctx, level, msg, attrs...)
+			return resp, err
+		})
+	}
+}
+
+// logURL formats the URL with the sensitive query parameters redacted and without the userinfo.
+func (l *RequestLogger) logURL(u *url.URL) string {
+	r := *l.redactor.url(u)
+	r.User = nil
+	return r.String()
+}
+
+func (l *RequestLogger) headersAttr(key string, h http.Header) slog.Attr {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		values := h[name]
+		if l.redactor.redactsHeader(name) {
+			values = []string{RedactedValue}
+		}
+		if len(values) == 1 {
+			attrs = append(attrs, slog.String(name, values[0]))
+		} else {
+			attrs = append(attrs, slog.Any(name, values))
+		}
+	}
+	return slog.Group(key, attrs...)
+}
+
+// requestBody returns the prefix of a replayable request body.
+func (l *RequestLogger) requestBody(req *http.Request) (string, bool) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return "", false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", false
+	}
+	defer body.Close()
+	b, err := io.ReadAll(io.LimitReader(body, int64(l.maxBodySize)))
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// responseBody reads the prefix of the response body and restores it in front of the remaining stream.
+func (l *RequestLogger) responseBody(resp *http.Response) string {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return ""
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, int64(l.maxBodySize)))
+	resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(b), errorReader{err}, resp.Body), Closer: resp.Body}
+	return string(b)
+}
+
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errorReader replays a read error after the logged prefix, or signals EOF of the prefix if nil.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MetricLabels identify the series a request is recorded in. The Route is the template set with
+// the WithRouteTemplate functional option parameter, so that the raw URL never becomes a label.
+// StatusClass is the class of the response status code, e.g. `2xx`, or `error` when no response was received;
+// it is empty for in-flight requests.
+type MetricLabels struct {
+	Method      string
+	Host        string
+	Route       string
+	StatusClass string
+}
+
+// RequestMetrics are the measurements of a finished request attempt.
+// Sizes are taken from Content-Length and are -1 when unknown.
+type RequestMetrics struct {
+	Duration     time.Duration
+	RequestSize  int64
+	ResponseSize int64
+	// Attempt is the attempt number within the logical call, see Attempt. Attempts greater than zero are retries.
+	Attempt int
+}
+
+// MetricsRecorder receives the measurements of every request attempt. Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	// AddInFlight is called with +1 when a request attempt starts and with -1 when it finishes.
+	AddInFlight(labels MetricLabels, delta int)
+	// ObserveRequest is called when a request attempt finishes.
+	ObserveRequest(labels MetricLabels, metrics RequestMetrics)
+}
+
+// NopMetricsRecorder is a MetricsRecorder that discards all measurements.
+type NopMetricsRecorder struct{}
+
+func (NopMetricsRecorder) AddInFlight(MetricLabels, int)               {}
+func (NopMetricsRecorder) ObserveRequest(MetricLabels, RequestMetrics) {}
+
+// WithRouteTemplate sets the route template of the request, e.g. `/users/{id}`, which is used
+// instead of the URL path in order to keep the cardinality of metrics and span names low.
+func WithRouteTemplate(template string) RequestParameter {
+	return func(opts *RequestParameters) {
+		opts.route = template
+	}
+}
+
+// RouteTemplate returns the route template of the request, if one was set with WithRouteTemplate.
+func RouteTemplate(req *http.Request) string {
+	return requestParametersFromContext(req.Context()).route
+}
+
+// MetricsMiddleware returns the Middleware that feeds the given MetricsRecorder.
+func MetricsMiddleware(recorder MetricsRecorder) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			labels := MetricLabels{
+				Method: req.Method,
+				Host:   req.URL.Host,
+				Route:  RouteTemplate(req),
+			}
+			recorder.AddInFlight(labels, 1)
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			duration := time.Since(start)
+			recorder.AddInFlight(labels, -1)
+
+			requestSize := req.ContentLength
+			if req.Body == nil || req.Body == http.NoBody {
+				requestSize = 0
+			}
+			m := RequestMetrics{
+				Duration:     duration,
+				RequestSize:  requestSize,
+				ResponseSize: -1,
+				Attempt:      Attempt(req),
+			}
+			labels.StatusClass = "error"
+			if err == nil {
+				labels.StatusClass = strconv.Itoa(resp.StatusCode/100) + "xx"
+				m.ResponseSize = resp.ContentLength
+			}
+			recorder.ObserveRequest(labels, m)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	inFlight atomic.Int32
+	observed []MetricLabels
+	metrics  []RequestMetrics
+}
+
+func (r *recordingMetrics) AddInFlight(_ MetricLabels, delta int) {
+	r.inFlight.Add(int32(delta))
+}
+
+func (r *recordingMetrics) ObserveRequest(labels MetricLabels, m RequestMetrics) {
+	r.observed = append(r.observed, labels)
+	r.metrics = append(r.metrics, m)
+}
+
+func newMetricsClient(recorder MetricsRecorder) *Client {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(req.URL.Path, "/fail") {
+			return nil, errors.New("connection refused")
+		}
+		resp := httpmock.NewStringResponse(http.StatusOK, `{"id":42}`)
+		resp.ContentLength = 9
+		if req.Method == http.MethodPost {
+			resp.StatusCode = http.StatusConflict
+		}
+		return resp, nil
+	})
+	return NewWithTransport(mt).WithMetrics(recorder)
+}
+
+func TestClient_WithMetrics(t *testing.T) {
+	recorder := &recordingMetrics{}
+	c := newMetricsClient(recorder)
+
+	_, err := c.Get(context.Background(), "https://api.example.com/users/42", WithRouteTemplate("/users/{id}"))
+	require.NoError(t, err)
+	_, err = c.Post(context.Background(), "https://api.example.com/users", strings.NewReader(`{"name":"a"}`))
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), "https://api.example.com/fail")
+	require.Error(t, err)
+
+	assert.Equal(t, []MetricLabels{
+		{Method: http.MethodGet, Host: "api.example.com", Route: "/users/{id}", StatusClass: "2xx"},
+		{Method: http.MethodPost, Host: "api.example.com", StatusClass: "4xx"},
+		{Method: http.MethodGet, Host: "api.example.com", StatusClass: "error"},
+	}, recorder.observed)
+	assert.Equal(t, int64(0), recorder.metrics[0].RequestSize)
+	assert.Equal(t, int64(9), recorder.metrics[0].ResponseSize)
+	assert.Equal(t, int64(12), recorder.metrics[1].RequestSize)
+	assert.Equal(t, int64(-1), recorder.metrics[2].ResponseSize)
+	assert.Equal(t, int32(0), recorder.inFlight.Load())
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector("api").
+		WithDurationBuckets(1, 0.5).
+		WithSizeBuckets(10, 100)
+	c := newMetricsClient(collector)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(context.Background(), "https://api.example.com/users/42", WithRouteTemplate("/users/{id}"))
+		require.NoError(t, err)
+	}
+	_, err := c.Get(context.Background(), "https://api.example.com/fail")
+	require.Error(t, err)
+	collector.ObserveRequest(MetricLabels{Method: http.MethodGet, Host: "api.example.com", Route: `/a"b`, StatusClass: "5xx"},
+		RequestMetrics{Duration: 2 * time.Second, RequestSize: -1, ResponseSize: 50, Attempt: 1})
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE api_requests_total counter",
+		`api_requests_total{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx"} 2`,
+		`api_requests_total{method="GET",host="api.example.com",route="",status_class="error"} 1`,
+		"# TYPE api_request_duration_seconds histogram",
+		`api_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx",le="0.5"} 2`,
+		`api_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/a\"b",status_class="5xx",le="1"} 0`,
+		`api_request_duration_seconds_bucket{method="GET",host="api.example.com",route="/a\"b",status_class="5xx",le="+Inf"} 1`,
+		`api_request_duration_seconds_sum{method="GET",host="api.example.com",route="/a\"b",status_class="5xx"} 2`,
+		`api_request_duration_seconds_count{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx"} 2`,
+		"# TYPE api_requests_in_flight gauge",
+		`api_requests_in_flight{method="GET",host="api.example.com",route="/users/{id}"} 0`,
+		`api_request_size_bytes_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx",le="10"} 2`,
+		`api_response_size_bytes_bucket{method="GET",host="api.example.com",route="/users/{id}",status_class="2xx",le="10"} 2`,
+		`api_response_size_bytes_bucket{method="GET",host="api.example.com",route="/a\"b",status_class="5xx",le="10"} 0`,
+		`api_response_size_bytes_bucket{method="GET",host="api.example.com",route="/a\"b",status_class="5xx",le="100"} 1`,
+		`api_retries_total{method="GET",host="api.example.com",route="/a\"b"} 1`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.NotContains(t, out, `api_request_size_bytes_count{method="GET",host="api.example.com",route="/a\"b"`)
+	assert.NotContains(t, out, `api_response_size_bytes_count{method="GET",host="api.example.com",route="",status_class="error"}`)
+}
+
+func TestPrometheusCollector_ChangeBuckets(t *testing.T) {
+	collector := NewPrometheusCollector("api")
+	labels := MetricLabels{Method: http.MethodGet, Host: "api.example.com", StatusClass: "2xx"}
+	collector.ObserveRequest(labels, RequestMetrics{Duration: time.Second, RequestSize: 10, ResponseSize: 20})
+
+	collector.WithDurationBuckets(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12).WithSizeBuckets(1, 2, 3, 4, 5, 6, 7, 8)
+	collector.ObserveRequest(labels, RequestMetrics{Duration: 12 * time.Second, RequestSize: 8, ResponseSize: 8})
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+	for _, line := range []string{
+		`api_request_duration_seconds_bucket{method="GET",host="api.example.com",route="",status_class="2xx",le="12"} 1`,
+		`api_request_duration_seconds_count{method="GET",host="api.example.com",route="",status_class="2xx"} 1`,
+		`api_request_size_bytes_bucket{method="GET",host="api.example.com",route="",status_class="2xx",le="8"} 1`,
+		`api_response_size_bytes_count{method="GET",host="api.example.com",route="",status_class="2xx"} 1`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
+
+func TestClient_WithMetrics_Nop(t *testing.T) {
+	c := newMetricsClient(NopMetricsRecorder{})
+	resp, err := c.Get(context.Background(), "https://api.example.com/users/42")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,24 @@
+package httpclient
+
+import "net/http"
+
+// Middleware wraps the transport of the Client in order to intercept every Request and Response.
+// Middlewares are invoked after the Request has been prepared and before any credentials are applied.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc allows the use of ordinary functions as an http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type attemptKey struct{}
+
+// Attempt returns the zero-based number of the attempt a request belongs to, within the logical call made
+// through the Client. The first request of a call is attempt 0; hedged copies are additional attempts.
+// Middlewares see every attempt separately.
+func Attempt(req *http.Request) int {
+	n, _ := req.Context().Value(attemptKey{}).(int)
+	return n
+}
@@ -0,0 +1,45 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithMiddleware(t *testing.T) {
+	var calls []string
+	recorder := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" request")
+				resp, err := next.RoundTrip(req)
+				calls = append(calls, name+" response")
+				return resp, err
+			})
+		}
+	}
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		// Middlewares run before the credentials are applied.
+		calls = append(calls, "transport "+req.Header.Get("Authorization"))
+		return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+	})
+	c := NewWithTransport(mt).
+		WithAuthenticator(BearerToken("t0k3n")).
+		WithMiddleware(recorder("first"), recorder("second"))
+
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"first request",
+		"second request",
+		"transport Bearer t0k3n",
+		"second response",
+		"first response",
+	}, calls)
+}
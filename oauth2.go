@@ -0,0 +1,219 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultOAuth2ExpiryDelta is the period before the actual token expiration
+// during which a cached token is considered expired and will be refreshed.
+const DefaultOAuth2ExpiryDelta = 10 * time.Second
+
+// OAuth2Config configures the token endpoint and the grant used by OAuth2Authenticator.
+// The client credentials grant is used, unless a RefreshToken is set,
+// in which case the refresh token grant is used instead.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken is the initial refresh token. Rotated refresh tokens returned by the token endpoint
+	// replace this value for subsequent refreshes.
+	RefreshToken string
+	// EndpointParams are additional form parameters sent to the token endpoint, e.g. `audience`.
+	EndpointParams map[string]string
+	// ExpiryDelta overrides DefaultOAuth2ExpiryDelta, when non-zero.
+	ExpiryDelta time.Duration
+	// ClientCredentialsInBody sends the client credentials as form parameters
+	// instead of using HTTP Basic authentication.
+	ClientCredentialsInBody bool
+	// HTTPClient sends the token endpoint requests. When nil, the requests are sent through the base transport
+	// of the Client passed to NewOAuth2Authenticator, bypassing its middlewares.
+	HTTPClient *http.Client
+}
+
+// OAuth2Token is an access token issued by the token endpoint.
+type OAuth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"-"`
+}
+
+// Valid reports whether the token is set and will not expire within the given period.
+func (t OAuth2Token) Valid(now time.Time, expiryDelta time.Duration) bool {
+	if t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || now.Add(expiryDelta).Before(t.Expiry)
+}
+
+// OAuth2Authenticator is an Authenticator that obtains Bearer tokens from an OAuth2 token endpoint.
+// Tokens are cached until shortly before their expiration. Concurrent requests that need a new token
+// share a single token endpoint call. When a request is rejected with 401 Unauthorized,
+// the cached token is discarded and the request is retried once with a fresh token.
+type OAuth2Authenticator struct {
+	client *Client
+	config OAuth2Config
+	group  singleflight.Group
+	now    func() time.Time
+
+	mu           sync.Mutex
+	token        OAuth2Token
+	refreshToken string
+}
+
+// NewOAuth2Authenticator creates an OAuth2Authenticator for the given Client.
+// Unless OAuth2Config.HTTPClient is set, token endpoint requests are sent directly through the base transport
+// of the Client, so they are neither authenticated nor subject to its middlewares, e.g. a ConcurrencyLimiter,
+// and the same Client can be safely configured with the returned Authenticator.
+func NewOAuth2Authenticator(client *Client, config OAuth2Config) *OAuth2Authenticator {
+	if config.ExpiryDelta == 0 {
+		config.ExpiryDelta = DefaultOAuth2ExpiryDelta
+	}
+	return &OAuth2Authenticator{
+		client:       client,
+		config:       config,
+		now:          time.Now,
+		refreshToken: config.RefreshToken,
+	}
+}
+
+func (a *OAuth2Authenticator) Authenticate(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	req.Header.Set("Authorization", tokenType+" "+token.AccessToken)
+	return nil
+}
+
+// HandleChallenge discards the cached token if it was used for the rejected request.
+func (a *OAuth2Authenticator) HandleChallenge(resp *http.Response) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if resp.Request != nil && a.token.AccessToken != "" &&
+		strings.HasSuffix(resp.Request.Header.Get("Authorization"), " "+a.token.AccessToken) {
+		a.token = OAuth2Token{}
+	}
+	return true, nil
+}
+
+// Token returns the cached token, or fetches a new one from the token endpoint when it is about to expire.
+func (a *OAuth2Authenticator) Token(ctx context.Context) (OAuth2Token, error) {
+	a.mu.Lock()
+	token := a.token
+	a.mu.Unlock()
+	if token.Valid(a.now(), a.config.ExpiryDelta) {
+		return token, nil
+	}
+
+	// The token endpoint call is shared among concurrent callers,
+	// so it must not be cancelled when the context of the first caller is done.
+	ch := a.group.DoChan("token", func() (any, error) {
+		return a.fetchToken(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return OAuth2Token{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return OAuth2Token{}, res.Err
+		}
+		return res.Val.(OAuth2Token), nil
+	}
+}
+
+// httpClient returns the net/http Client for token endpoint requests.
+// The base transport is resolved on every call, so changes made through WithBaseTransport take effect immediately.
+func (a *OAuth2Authenticator) httpClient() *http.Client {
+	if a.config.HTTPClient != nil {
+		return a.config.HTTPClient
+	}
+	return &http.Client{Timeout: DefaultTimeout, Transport: a.client.base}
+}
+
+type oauth2TokenResponse struct {
+	OAuth2Token
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (a *OAuth2Authenticator) fetchToken(ctx context.Context) (OAuth2Token, error) {
+	a.mu.Lock()
+	refreshToken := a.refreshToken
+	a.mu.Unlock()
+
+	form := url.Values{}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(a.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.config.Scopes, " "))
+	}
+	for k, v := range a.config.EndpointParams {
+		form.Set(k, v)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/x-www-form-urlencoded",
+		"Accept":       "application/json",
+	}
+	if a.config.ClientCredentialsInBody {
+		form.Set("client_id", a.config.ClientID)
+		form.Set("client_secret", a.config.ClientSecret)
+	} else {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+		headers["Authorization"] = req.Header.Get("Authorization")
+	}
+
+	req, err := NewRequest(ctx, http.MethodPost, a.config.TokenURL, strings.NewReader(form.Encode()), WithHeaders(headers))
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	issuedAt := a.now()
+	resp, err := a.httpClient().Do(req)
+	if err != nil {
+		return OAuth2Token{}, err
+	}
+	tr := oauth2TokenResponse{}
+	if err := DeserializeJSON(resp, &tr); err != nil && resp.StatusCode < http.StatusBadRequest {
+		return OAuth2Token{}, fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest || tr.Error != "" {
+		return OAuth2Token{}, fmt.Errorf("oauth2: token request failed with status %d: %s",
+			resp.StatusCode, strings.TrimSpace(tr.Error+" "+tr.ErrorDescription))
+	}
+	if tr.AccessToken == "" {
+		return OAuth2Token{}, errors.New("oauth2: token response does not contain an access token")
+	}
+
+	token := tr.OAuth2Token
+	if tr.ExpiresIn > 0 {
+		token.Expiry = issuedAt.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = token
+	if token.RefreshToken != "" {
+		a.refreshToken = token.RefreshToken
+	}
+	return token, nil
+}
@@ -0,0 +1,177 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgepsarakis/go-httpclient"
+	"github.com/georgepsarakis/go-httpclient/httptesting"
+)
+
+const testTokenURL = "https://auth.example.com/oauth/token"
+
+func tokenResponder(t *testing.T, calls *atomic.Int32, expiresIn int) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		n := calls.Add(1)
+		require.NoError(t, req.ParseForm())
+		assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client-id", user)
+		assert.Equal(t, "client-secret", pass)
+		// Allow concurrent callers to pile up while the token is being issued.
+		time.Sleep(10 * time.Millisecond)
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"token_type":    "bearer",
+			"expires_in":    expiresIn,
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}
+}
+
+func countingResponder(calls *atomic.Int32, statusCode int) httpmock.Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return httpmock.NewStringResponse(statusCode, http.StatusText(statusCode)), nil
+	}
+}
+
+func newOAuth2Mock(t *testing.T, config httpclient.OAuth2Config) (*httptesting.Mock, *httpclient.OAuth2Authenticator) {
+	c := httptesting.NewMock(t)
+	config.TokenURL = testTokenURL
+	config.ClientID = "client-id"
+	config.ClientSecret = "client-secret"
+	authenticator := httpclient.NewOAuth2Authenticator(c.Client, config)
+	c.WithAuthenticator(authenticator)
+	return c, authenticator
+}
+
+func TestOAuth2Authenticator_ClientCredentials(t *testing.T) {
+	c, _ := newOAuth2Mock(t, httpclient.OAuth2Config{Scopes: []string{"read", "write"}})
+	tokenCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodPost, testTokenURL).
+		Responder(tokenResponder(t, tokenCalls, 3600)).
+		Register()
+	apiCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodGet, "https://api.example.com/items",
+		httpclient.WithHeaders(map[string]string{"Authorization": "Bearer token-1"})).
+		Responder(countingResponder(apiCalls, http.StatusOK)).
+		Register()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "https://api.example.com/items")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), tokenCalls.Load())
+	assert.Equal(t, int32(10), apiCalls.Load())
+}
+
+func TestOAuth2Authenticator_RetryOnUnauthorized(t *testing.T) {
+	c, authenticator := newOAuth2Mock(t, httpclient.OAuth2Config{RefreshToken: "refresh-0"})
+	tokenCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodPost, testTokenURL).
+		Responder(tokenResponder(t, tokenCalls, 3600)).
+		Register()
+	c.NewMockRequest(http.MethodGet, "https://api.example.com/items").
+		Responder(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "Bearer token-2" {
+				return httpmock.NewStringResponse(http.StatusUnauthorized, "expired"), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, "OK"), nil
+		}).
+		Register()
+
+	resp, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), tokenCalls.Load())
+
+	token, err := authenticator.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+	assert.Equal(t, "refresh-2", token.RefreshToken)
+}
+
+func TestOAuth2Authenticator_RetriesOnlyOnce(t *testing.T) {
+	c, _ := newOAuth2Mock(t, httpclient.OAuth2Config{})
+	tokenCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodPost, testTokenURL).
+		Responder(tokenResponder(t, tokenCalls, 3600)).
+		Register()
+	apiCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodGet, "https://api.example.com/items").
+		Responder(countingResponder(apiCalls, http.StatusUnauthorized)).
+		Register()
+
+	resp, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, int32(2), tokenCalls.Load())
+	assert.Equal(t, int32(2), apiCalls.Load())
+}
+
+func TestOAuth2Authenticator_TokenEndpointError(t *testing.T) {
+	c, _ := newOAuth2Mock(t, httpclient.OAuth2Config{})
+	c.NewMockRequest(http.MethodPost, testTokenURL).
+		RespondWithJSON(http.StatusBadRequest, `{"error": "invalid_client", "error_description": "unknown client"}`).
+		Register()
+
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.ErrorContains(t, err,
+		"[httpclient][authentication] oauth2: token request failed with status 400: invalid_client unknown client")
+}
+
+func TestOAuth2Authenticator_BypassesMiddlewares(t *testing.T) {
+	c, _ := newOAuth2Mock(t, httpclient.OAuth2Config{})
+	c.WithConcurrencyLimiter(httpclient.NewConcurrencyLimiter(1))
+	tokenCalls := &atomic.Int32{}
+	c.NewMockRequest(http.MethodPost, testTokenURL).
+		Responder(tokenResponder(t, tokenCalls, 3600)).
+		Register()
+	c.NewMockRequest(http.MethodGet, "https://api.example.com/items",
+		httpclient.WithHeaders(map[string]string{"Authorization": "Bearer token-1"})).
+		RespondWithJSON(http.StatusOK, `{}`).
+		Register()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := c.Get(ctx, "https://api.example.com/items")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), tokenCalls.Load())
+}
+
+func TestOAuth2Authenticator_HTTPClient(t *testing.T) {
+	tokenCalls := &atomic.Int32{}
+	tokenTransport := httpmock.NewMockTransport()
+	tokenTransport.RegisterResponder(http.MethodPost, testTokenURL, tokenResponder(t, tokenCalls, 3600))
+	c, _ := newOAuth2Mock(t, httpclient.OAuth2Config{HTTPClient: &http.Client{Transport: tokenTransport}})
+	c.NewMockRequest(http.MethodGet, "https://api.example.com/items",
+		httpclient.WithHeaders(map[string]string{"Authorization": "Bearer token-1"})).
+		RespondWithJSON(http.StatusOK, `{}`).
+		Register()
+
+	resp, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(1), tokenCalls.Load())
+}
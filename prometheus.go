@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds, of the request duration histogram buckets.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds, in bytes, of the request and response size histogram buckets.
+var DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// PrometheusCollector is a MetricsRecorder that exposes the recorded metrics in the Prometheus text format:
+//
+//   - `<namespace>_requests_total`: counter of finished request attempts
+//   - `<namespace>_request_duration_seconds`: histogram of request attempt durations
+//   - `<namespace>_requests_in_flight`: gauge of in-flight request attempts
+//   - `<namespace>_request_size_bytes` and `<namespace>_response_size_bytes`: histograms of known body sizes
+//   - `<namespace>_retries_total`: counter of request attempts after the first one of each call
+//
+// All the metrics are labelled by `method`, `host` and `route`; finished requests are also labelled by `status_class`.
+// The collector implements http.Handler, so it can be mounted on the metrics endpoint of the application.
+type PrometheusCollector struct {
+	namespace       string
+	durationBuckets []float64
+	sizeBuckets     []float64
+
+	mu            sync.Mutex
+	requests      map[MetricLabels]float64
+	durations     map[MetricLabels]*histogram
+	requestSizes  map[MetricLabels]*histogram
+	responseSizes map[MetricLabels]*histogram
+	inFlight      map[MetricLabels]float64
+	retries       map[MetricLabels]float64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, upper := range buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// NewPrometheusCollector creates a PrometheusCollector whose metric names start with the given namespace,
+// e.g. `httpclient`.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return &PrometheusCollector{
+		namespace:       namespace,
+		durationBuckets: DefaultDurationBuckets,
+		sizeBuckets:     DefaultSizeBuckets,
+		requests:        make(map[MetricLabels]float64),
+		durations:       make(map[MetricLabels]*histogram),
+		requestSizes:    make(map[MetricLabels]*histogram),
+		responseSizes:   make(map[MetricLabels]*histogram),
+		inFlight:        make(map[MetricLabels]float64),
+		retries:         make(map[MetricLabels]float64),
+	}
+}
+
+// WithDurationBuckets sets the upper bounds, in seconds, of the request duration histogram buckets.
+// The request durations observed so far are discarded.
+func (c *PrometheusCollector) WithDurationBuckets(buckets ...float64) *PrometheusCollector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.durationBuckets = sortedBuckets(buckets)
+	c.durations = make(map[MetricLabels]*histogram)
+	return c
+}
+
+// WithSizeBuckets sets the upper bounds, in bytes, of the request and response size histogram buckets.
+// The request and response sizes observed so far are discarded.
+func (c *PrometheusCollector) WithSizeBuckets(buckets ...float64) *PrometheusCollector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sizeBuckets = sortedBuckets(buckets)
+	c.requestSizes = make(map[MetricLabels]*histogram)
+	c.responseSizes = make(map[MetricLabels]*histogram)
+	return c
+}
+
+func sortedBuckets(buckets []float64) []float64 {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return b
+}
+
+func (c *PrometheusCollector) AddInFlight(labels MetricLabels, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight[labels] += float64(delta)
+}
+
+func (c *PrometheusCollector) ObserveRequest(labels MetricLabels, m RequestMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests[labels]++
+	c.histogramFor(c.durations, labels, c.durationBuckets).observe(c.durationBuckets, m.Duration.Seconds())
+	if m.RequestSize >= 0 {
+		c.histogramFor(c.requestSizes, labels, c.sizeBuckets).observe(c.sizeBuckets, float64(m.RequestSize))
+	}
+	if m.ResponseSize >= 0 {
+		c.histogramFor(c.responseSizes, labels, c.sizeBuckets).observe(c.sizeBuckets, float64(m.ResponseSize))
+	}
+	if m.Attempt > 0 {
+		labels.StatusClass = ""
+		c.retries[labels]++
+	}
+}
+
+func (c *PrometheusCollector) histogramFor(series map[MetricLabels]*histogram, labels MetricLabels, buckets []float64) *histogram {
+	h, ok := series[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		series[labels] = h
+	}
+	return h
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (c *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	c.writeCounter(cw, "requests_total", "counter", "Total number of HTTP request attempts.", c.requests)
+	c.writeHistogram(cw, "request_duration_seconds", "Duration of HTTP request attempts in seconds.", c.durations, c.durationBuckets)
+	c.writeCounter(cw, "requests_in_flight", "gauge", "Number of in-flight HTTP request attempts.", c.inFlight)
+	c.writeHistogram(cw, "request_size_bytes", "Size of HTTP request bodies in bytes.", c.requestSizes, c.sizeBuckets)
+	c.writeHistogram(cw, "response_size_bytes", "Size of HTTP response bodies in bytes.", c.responseSizes, c.sizeBuckets)
+	c.writeCounter(cw, "retries_total", "counter", "Total number of HTTP request attempts after the first one of each call.", c.retries)
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP exposes the metrics in the Prometheus text format.
+func (c *PrometheusCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+func (c *PrometheusCollector) writeCounter(w *countingWriter, name, kind, help string, series map[MetricLabels]float64) {
+	name = c.namespace + "_" + name
+	w.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, labels := range sortedLabels(series) {
+		w.printf("%s{%s} %s\n", name, formatLabels(labels), formatFloat(series[labels]))
+	}
+}
+
+func (c *PrometheusCollector) writeHistogram(w *countingWriter, name, help string, series map[MetricLabels]*histogram, buckets []float64) {
+	name = c.namespace + "_" + name
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, labels := range sortedLabels(series) {
+		h := series[labels]
+		l := formatLabels(labels)
+		for i, upper := range buckets {
+			w.printf("%s_bucket{%s,le=\"%s\"} %d\n", name, l, formatFloat(upper), h.counts[i])
+		}
+		w.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, h.count)
+		w.printf("%s_sum{%s} %s\n%s_count{%s} %d\n", name, l, formatFloat(h.sum), name, l, h.count)
+	}
+}
+
+func sortedLabels[V any](series map[MetricLabels]V) []MetricLabels {
+	keys := make([]MetricLabels, 0, len(series))
+	for k := range series {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return formatLabels(keys[i]) < formatLabels(keys[j])
+	})
+	return keys
+}
+
+func formatLabels(l MetricLabels) string {
+	pairs := []string{
+		`method="` + escapeLabelValue(l.Method) + `"`,
+		`host="` + escapeLabelValue(l.Host) + `"`,
+		`route="` + escapeLabelValue(l.Route) + `"`,
+	}
+	if l.StatusClass != "" {
+		pairs = append(pairs, `status_class="`+escapeLabelValue(l.StatusClass)+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
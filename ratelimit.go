@@ -0,0 +1,252 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is wrapped by the errors returned when a RateLimiter rejects a request.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimiter is a client-side token bucket rate limiter. By default, a single bucket is shared by all requests.
+// Requests block until a token is available, unless the wait would exceed the request context deadline,
+// in which case an error tagged with ErrorTagRateLimited is returned immediately.
+// Buckets that have refilled completely are discarded, so that keys which are no longer used do not retain memory.
+type RateLimiter struct {
+	rate          float64
+	burst         int
+	key           func(req *http.Request) string
+	noWait        bool
+	serverHeaders bool
+	now           func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// keySweepInterval is the minimum interval between scans for idle per-key state.
+const keySweepInterval = time.Minute
+
+// NewRateLimiter creates a RateLimiter that allows `rate` requests per second on average,
+// with bursts of at most `burst` requests.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		panic("rate must be positive")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// PerHost maintains a separate bucket for each request host.
+func (l *RateLimiter) PerHost() *RateLimiter {
+	return l.WithKeyFunc(func(req *http.Request) string {
+		return req.URL.Host
+	})
+}
+
+// WithKeyFunc maintains a separate bucket for each key returned by the given function.
+func (l *RateLimiter) WithKeyFunc(key func(req *http.Request) string) *RateLimiter {
+	l.key = key
+	return l
+}
+
+// WithoutWait rejects requests immediately with an ErrorTagRateLimited error, instead of waiting for a token.
+func (l *RateLimiter) WithoutWait() *RateLimiter {
+	l.noWait = true
+	return l
+}
+
+// WithServerHeaders pauses the bucket of a request when the server signals that its quota has been exhausted,
+// using the `Retry-After`, `RateLimit-Remaining`/`RateLimit-Reset` and
+// `X-RateLimit-Remaining`/`X-RateLimit-Reset` response headers.
+func (l *RateLimiter) WithServerHeaders() *RateLimiter {
+	l.serverHeaders = true
+	return l
+}
+
+// Middleware returns the Middleware that applies the rate limit.
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := ""
+			if l.key != nil {
+				key = l.key(req)
+			}
+			if err := l.wait(req, key); err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if err == nil && l.serverHeaders {
+				if until, ok := serverRateLimitReset(resp, l.now()); ok {
+					l.mu.Lock()
+					l.bucket(key).pause(until)
+					l.mu.Unlock()
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// bucket returns the bucket of the key, creating it if needed. The lock must be held, so that the bucket
+// is not discarded before it is used.
+func (l *RateLimiter) bucket(key string) *tokenBucket {
+	now := l.now()
+	if now.Sub(l.lastSweep) >= keySweepInterval {
+		l.lastSweep = now
+		for k, b := range l.buckets {
+			if b.full(now) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{rate: l.rate, burst: float64(l.burst), tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	return b
+}
+
+func (l *RateLimiter) wait(req *http.Request, key string) error {
+	ctx := req.Context()
+	now := l.now()
+	maxDelay := time.Duration(math.MaxInt64)
+	if l.noWait {
+		maxDelay = 0
+	} else if deadline, ok := ctx.Deadline(); ok {
+		maxDelay = deadline.Sub(now)
+	}
+	l.mu.Lock()
+	b := l.bucket(key)
+	delay, ok := b.reserve(now, maxDelay)
+	l.mu.Unlock()
+	if !ok {
+		err := fmt.Errorf("%w, retry in %s", ErrRateLimited, delay.Round(time.Millisecond))
+		if key != "" {
+			err = fmt.Errorf("%w for %s, retry in %s", ErrRateLimited, key, delay.Round(time.Millisecond))
+		}
+		return newBaseError(err, ErrorTagRateLimited)
+	}
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+type tokenBucket struct {
+	mu           sync.Mutex
+	rate         float64
+	burst        float64
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// reserve takes a token and returns the time to wait until it becomes available.
+// If the wait exceeds maxDelay, no token is taken.
+func (b *tokenBucket) reserve(now time.Time, maxDelay time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	tokens := b.tokens - 1
+	var delay time.Duration
+	if tokens < 0 {
+		delay = time.Duration(-tokens / b.rate * float64(time.Second))
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > delay {
+		delay = blocked
+	}
+	if delay > maxDelay {
+		return delay, false
+	}
+	b.tokens = tokens
+	return delay, true
+}
+
+// full reports whether the bucket has refilled completely and is not paused,
+// in which case it is equivalent to a new bucket.
+func (b *tokenBucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst && !now.Before(b.blockedUntil)
+}
+
+// cancel returns a reserved token that was not used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+func (b *tokenBucket) pause(until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// serverRateLimitReset returns the time until which the server will reject requests, if the quota is exhausted.
+func serverRateLimitReset(resp *http.Response, now time.Time) (time.Time, bool) {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if until, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return until, true
+		}
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		remaining, err := strconv.Atoi(resp.Header.Get(prefix + "Remaining"))
+		if err != nil || remaining > 0 {
+			continue
+		}
+		reset, err := strconv.ParseInt(resp.Header.Get(prefix+"Reset"), 10, 64)
+		if err != nil {
+			continue
+		}
+		// Some APIs (e.g. GitHub) send the reset time as a Unix timestamp instead of a number of seconds.
+		if reset > 1e9 {
+			return time.Unix(reset, 0), true
+		}
+		return now.Add(time.Duration(reset) * time.Second), true
+	}
+	return time.Time{}, false
+}
+
+// parseRetryAfter parses the Retry-After header value, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
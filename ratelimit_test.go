@@ -0,0 +1,153 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitedClient(t *testing.T, limiter *RateLimiter, responder httpmock.Responder) (*Client, *httpmock.MockTransport) {
+	t.Helper()
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(responder)
+	return NewWithTransport(mt).WithRateLimiter(limiter), mt
+}
+
+func TestRateLimiter_WithoutWait(t *testing.T) {
+	c, mt := newRateLimitedClient(t, NewRateLimiter(1, 2).WithoutWait(),
+		httpmock.NewStringResponder(http.StatusOK, "OK"))
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(context.Background(), "https://api.example.com/items")
+		require.NoError(t, err)
+	}
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.True(t, HasErrorTag(err, ErrorTagRateLimited))
+	assert.ErrorContains(t, err, "[httpclient][rate_limited] rate limit exceeded, retry in")
+	assert.Equal(t, 2, mt.GetTotalCallCount())
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	c, mt := newRateLimitedClient(t, NewRateLimiter(20, 1),
+		httpmock.NewStringResponder(http.StatusOK, "OK"))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(context.Background(), "https://api.example.com/items")
+		require.NoError(t, err)
+	}
+
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, 3, mt.GetTotalCallCount())
+}
+
+func TestRateLimiter_ContextDeadline(t *testing.T) {
+	c, mt := newRateLimitedClient(t, NewRateLimiter(0.1, 1),
+		httpmock.NewStringResponder(http.StatusOK, "OK"))
+	_, err := c.Get(context.Background(), "https://api.example.com/items")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err = c.Get(ctx, "https://api.example.com/items")
+
+	// The next token is available in 10 seconds, so waiting is pointless.
+	assert.True(t, HasErrorTag(err, ErrorTagRateLimited))
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, 1, mt.GetTotalCallCount())
+}
+
+func TestRateLimiter_PerHost(t *testing.T) {
+	c, mt := newRateLimitedClient(t, NewRateLimiter(1, 1).PerHost().WithoutWait(),
+		httpmock.NewStringResponder(http.StatusOK, "OK"))
+
+	_, err := c.Get(context.Background(), "https://a.example.com/items")
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), "https://b.example.com/items")
+	require.NoError(t, err)
+	_, err = c.Get(context.Background(), "https://a.example.com/items")
+
+	assert.ErrorContains(t, err, "rate limit exceeded for a.example.com")
+	assert.Equal(t, 2, mt.GetTotalCallCount())
+}
+
+func TestRateLimiter_WithServerHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+	}{
+		{
+			name:    "Retry-After in seconds",
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"Retry-After": "30"},
+		},
+		{
+			name:   "RateLimit headers",
+			status: http.StatusOK,
+			headers: map[string]string{
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "30",
+			},
+		},
+		{
+			name:   "X-RateLimit headers with Unix timestamp",
+			status: http.StatusOK,
+			headers: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mt := newRateLimitedClient(t, NewRateLimiter(100, 10).WithServerHeaders().WithoutWait(),
+				func(req *http.Request) (*http.Response, error) {
+					resp := httpmock.NewStringResponse(tt.status, "")
+					for k, v := range tt.headers {
+						resp.Header.Set(k, v)
+					}
+					return resp, nil
+				})
+
+			_, err := c.Get(context.Background(), "https://api.example.com/items")
+			require.NoError(t, err)
+			_, err = c.Get(context.Background(), "https://api.example.com/items")
+
+			assert.True(t, HasErrorTag(err, ErrorTagRateLimited))
+			assert.Equal(t, 1, mt.GetTotalCallCount())
+		})
+	}
+}
+
+func TestRateLimiter_DiscardsIdleBuckets(t *testing.T) {
+	now := time.Now()
+	limiter := NewRateLimiter(0.025, 2).PerHost().WithoutWait()
+	limiter.now = func() time.Time { return now }
+	c, _ := newRateLimitedClient(t, limiter, httpmock.NewStringResponder(http.StatusOK, "OK"))
+
+	for _, host := range []string{"a", "b", "c"} {
+		_, err := c.Get(context.Background(), "https://"+host+".example.com/items")
+		require.NoError(t, err)
+	}
+	_, err := c.Get(context.Background(), "https://a.example.com/items")
+	require.NoError(t, err)
+	assert.Len(t, limiter.buckets, 3)
+
+	// A token takes 40 seconds to refill, so the bucket of a.example.com is not full yet.
+	now = now.Add(keySweepInterval)
+	_, err = c.Get(context.Background(), "https://d.example.com/items")
+	require.NoError(t, err)
+	assert.Len(t, limiter.buckets, 2)
+	assert.Contains(t, limiter.buckets, "a.example.com")
+	assert.Contains(t, limiter.buckets, "d.example.com")
+}
@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// redactor replaces the values of sensitive headers, query parameters and URL passwords with RedactedValue.
+type redactor struct {
+	headers map[string]bool
+	query   map[string]bool
+}
+
+// newRedactor creates a redactor for DefaultRedactedHeaders and DefaultRedactedQueryParameters.
+func newRedactor() *redactor {
+	r := &redactor{headers: make(map[string]bool), query: make(map[string]bool)}
+	r.addHeaders(DefaultRedactedHeaders...)
+	r.addQueryParameters(DefaultRedactedQueryParameters...)
+	return r
+}
+
+func (r *redactor) addHeaders(names ...string) {
+	for _, name := range names {
+		r.headers[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+func (r *redactor) addQueryParameters(names ...string) {
+	for _, name := range names {
+		r.query[name] = true
+	}
+}
+
+// redactsHeader reports whether the values of the given header are redacted. A nil redactor exposes every value.
+func (r *redactor) redactsHeader(name string) bool {
+	return r != nil && r.headers[http.CanonicalHeaderKey(name)]
+}
+
+// header returns the value to expose for the given header.
+func (r *redactor) header(name, value string) string {
+	if r.redactsHeader(name) {
+		return RedactedValue
+	}
+	return value
+}
+
+// url returns a copy of the URL with the password and the sensitive query parameters redacted.
+// A nil redactor returns the URL unchanged.
+func (r *redactor) url(u *url.URL) *url.URL {
+	if r == nil {
+		return u
+	}
+	c := *u
+	if _, ok := c.User.Password(); ok {
+		c.User = url.UserPassword(c.User.Username(), RedactedValue)
+	}
+	query := c.Query()
+	redacted := false
+	for name := range query {
+		if r.query[name] {
+			query.Set(name, RedactedValue)
+			redacted = true
+		}
+	}
+	if redacted {
+		c.RawQuery = query.Encode()
+	}
+	return &c
+}
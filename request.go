@@ -15,6 +15,13 @@ type RequestParameters struct {
 	queryParams url.Values
 	// Convert response with the following status code to error return values
 	errorCodes []int
+	skipAuth   bool
+	hedging    *HedgePolicy
+	timing     bool
+	// Route template used for metrics and tracing
+	route string
+	// Explicit idempotency key for POST and PATCH requests
+	idempotencyKey string
 }
 
 // QueryParameters returns a clone of the currently configured query parameters.
@@ -66,6 +73,13 @@ func WithHeaders(headers map[string]string) RequestParameter {
 	}
 }
 
+// WithoutAuth disables the Client Authenticator for the request.
+func WithoutAuth() RequestParameter {
+	return func(opts *RequestParameters) {
+		opts.skipAuth = true
+	}
+}
+
 func NewRequestParameters(opts ...RequestParameter) *RequestParameters {
 	rp := &RequestParameters{}
 	for _, o := range opts {
@@ -104,6 +118,7 @@ func NewRequest(ctx context.Context, method string, rawURL string, body io.Reade
 	if encodedQP := reqParams.queryParams.Encode(); encodedQP != "" {
 		parsedURL.RawQuery += encodedQP
 	}
+	ctx = context.WithValue(ctx, requestParametersKey{}, reqParams)
 	req, err := http.NewRequestWithContext(ctx, method, parsedURL.String(), body)
 	if err != nil {
 		return nil, err
@@ -111,3 +126,14 @@ func NewRequest(ctx context.Context, method string, rawURL string, body io.Reade
 	req.Header = reqParams.headers
 	return req, nil
 }
+
+type requestParametersKey struct{}
+
+// requestParametersFromContext returns the parameters the request was built with.
+// Requests that were not created with NewRequest have no parameters, so the zero value is returned.
+func requestParametersFromContext(ctx context.Context) *RequestParameters {
+	if rp, ok := ctx.Value(requestParametersKey{}).(*RequestParameters); ok {
+		return rp
+	}
+	return &RequestParameters{}
+}
@@ -0,0 +1,211 @@
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/georgepsarakis/go-httpclient/internal/conditional"
+)
+
+// DefaultRevalidatorMaxEntries is the default number of URLs for which a Revalidator keeps validators.
+const DefaultRevalidatorMaxEntries = 1000
+
+// DefaultRevalidatorMaxBodySize is the default size in bytes of the largest response body stored by a Revalidator.
+const DefaultRevalidatorMaxBodySize = 1 << 20
+
+// Revalidator turns repeated GET requests into conditional requests. The ETag and Last-Modified validators
+// of successful responses are stored per URL along with the response, and subsequent requests are sent
+// with the If-None-Match and If-Modified-Since headers. When the server responds with 304 Not Modified,
+// the stored response is returned instead, with its headers updated from the 304 response.
+//
+// Requests that already carry conditional headers are sent unchanged. Successful requests with unsafe methods
+// discard the stored response of their URL. Responses with bodies larger than the maximum body size are not stored.
+type Revalidator struct {
+	maxEntries  int
+	maxBodySize int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type validatedResponse struct {
+	url        string
+	vary       http.Header
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// NewRevalidator creates a Revalidator that keeps validators for up to DefaultRevalidatorMaxEntries URLs.
+func NewRevalidator() *Revalidator {
+	return &Revalidator{
+		maxEntries:  DefaultRevalidatorMaxEntries,
+		maxBodySize: DefaultRevalidatorMaxBodySize,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+// WithMaxEntries sets the number of URLs for which responses are stored.
+// The least recently used response is discarded when the limit is exceeded.
+func (r *Revalidator) WithMaxEntries(n int) *Revalidator {
+	if n < 1 {
+		panic("max entries must be positive")
+	}
+	r.maxEntries = n
+	return r
+}
+
+// WithMaxBodySize sets the size in bytes of the largest response body that is stored.
+func (r *Revalidator) WithMaxBodySize(maxSize int) *Revalidator {
+	r.maxBodySize = maxSize
+	return r
+}
+
+// Middleware returns the Middleware that sends conditional requests.
+func (r *Revalidator) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet {
+				resp, err := next.RoundTrip(req)
+				if err == nil && req.Method != http.MethodHead && resp.StatusCode < http.StatusBadRequest {
+					r.delete(req.URL.String())
+				}
+				return resp, err
+			}
+			if conditional.IsRequest(req) {
+				return next.RoundTrip(req)
+			}
+
+			stored := r.get(req)
+			outReq := req
+			if stored != nil {
+				outReq = conditional.Request(req, stored.header)
+			}
+			resp, err := next.RoundTrip(outReq)
+			if err != nil {
+				return nil, err
+			}
+			if stored != nil && resp.StatusCode == http.StatusNotModified {
+				return r.notModified(stored, req, resp), nil
+			}
+			if err := r.store(req, resp); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		})
+	}
+}
+
+// notModified returns the stored response, updated with the header fields of the 304 response.
+func (r *Revalidator) notModified(stored *validatedResponse, req *http.Request, resp *http.Response) *http.Response {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	header := conditional.UpdateHeader(stored.header, resp.Header)
+	updated := *stored
+	updated.header = header
+	r.put(&updated)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", stored.statusCode, http.StatusText(stored.statusCode)),
+		StatusCode:    stored.statusCode,
+		Proto:         resp.Proto,
+		ProtoMajor:    resp.ProtoMajor,
+		ProtoMinor:    resp.ProtoMinor,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(stored.body)),
+		ContentLength: int64(len(stored.body)),
+		Request:       req,
+	}
+}
+
+// store keeps the response if it is successful, carries a validator and its body does not exceed the maximum size.
+func (r *Revalidator) store(req *http.Request, resp *http.Response) error {
+	hasValidator := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	if resp.StatusCode != http.StatusOK || !hasValidator ||
+		strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") ||
+		resp.Header.Get("Vary") == "*" || resp.ContentLength > int64(r.maxBodySize) {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(r.maxBodySize)+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > r.maxBodySize {
+		// The body is returned to the caller unchanged, without being stored.
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return nil
+	}
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	vary := http.Header{}
+	for _, name := range conditional.VaryFields(resp.Header) {
+		vary[name] = req.Header.Values(name)
+	}
+	r.put(&validatedResponse{
+		url:        req.URL.String(),
+		vary:       vary,
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       body,
+	})
+	return nil
+}
+
+// get returns the stored response for the request URL, if the request matches the header fields nominated by Vary.
+func (r *Revalidator) get(req *http.Request) *validatedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	el, ok := r.entries[req.URL.String()]
+	if !ok {
+		return nil
+	}
+	r.order.MoveToFront(el)
+	stored := el.Value.(*validatedResponse)
+	for name, values := range stored.vary {
+		if strings.Join(values, ",") != strings.Join(req.Header.Values(name), ",") {
+			return nil
+		}
+	}
+	return stored
+}
+
+func (r *Revalidator) put(v *validatedResponse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if el, ok := r.entries[v.url]; ok {
+		el.Value = v
+		r.order.MoveToFront(el)
+		return
+	}
+	r.entries[v.url] = r.order.PushFront(v)
+	for r.order.Len() > r.maxEntries {
+		oldest := r.order.Remove(r.order.Back()).(*validatedResponse)
+		delete(r.entries, oldest.url)
+	}
+}
+
+func (r *Revalidator) delete(url string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if el, ok := r.entries[url]; ok {
+		r.order.Remove(el)
+		delete(r.entries, url)
+	}
+}
+
+// WithIfMatch makes the request conditional on the current entity tag of the resource, e.g. in order
+// to apply a Patch or Delete only if the resource has not been modified since it was read (optimistic concurrency).
+// The server responds with 412 Precondition Failed if none of the entity tags match.
+func WithIfMatch(etags ...string) RequestParameter {
+	return WithHeaders(map[string]string{"If-Match": strings.Join(etags, ", ")})
+}
@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagServer responds with 304 Not Modified when the request carries the current entity tag of the resource.
+func etagServer(version *atomic.Int32, notModified *atomic.Int32) *httpmock.MockTransport {
+	mt := httpmock.NewMockTransport()
+	mt.RegisterNoResponder(func(req *http.Request) (*http.Response, error) {
+		etag := fmt.Sprintf(`"v%d"`, version.Load())
+		if req.Method != http.MethodGet {
+			if match := req.Header.Get("If-Match"); match != "" && match != etag {
+				return httpmock.NewStringResponse(http.StatusPreconditionFailed, ""), nil
+			}
+			version.Add(1)
+			return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+		}
+		if req.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			resp := httpmock.NewStringResponse(http.StatusNotModified, "")
+			resp.Header.Set("ETag", etag)
+			resp.Header.Set("X-RateLimit-Remaining", "59")
+			return resp, nil
+		}
+		resp := httpmock.NewStringResponse(http.StatusOK, "body "+etag)
+		resp.Header.Set("ETag", etag)
+		resp.Header.Set("Content-Type", "text/plain")
+		return resp, nil
+	})
+	return mt
+}
+
+func TestClient_WithRevalidator(t *testing.T) {
+	version, notModified := &atomic.Int32{}, &atomic.Int32{}
+	version.Store(1)
+	c := NewWithTransport(etagServer(version, notModified)).WithRevalidator(NewRevalidator())
+	ctx := context.Background()
+	const url = "https://api.example.com/repos/1"
+
+	resp, err := c.Get(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, `body "v1"`, string(MustInterceptResponseBody(resp)))
+
+	resp, err = c.Get(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `body "v1"`, string(MustInterceptResponseBody(resp)))
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "59", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// A successful update discards the stored response.
+	_, err = c.Patch(ctx, url, strings.NewReader("{}"), WithIfMatch(resp.Header.Get("ETag")))
+	require.NoError(t, err)
+	resp, err = c.Get(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, `body "v2"`, string(MustInterceptResponseBody(resp)))
+	assert.Equal(t, int32(1), notModified.Load())
+}
+
+func TestWithIfMatch(t *testing.T) {
+	version, notModified := &atomic.Int32{}, &atomic.Int32{}
+	version.Store(2)
+	c := NewWithTransport(etagServer(version, notModified))
+
+	resp, err := c.Delete(context.Background(), "https://api.example.com/repos/1", WithIfMatch(`"v1"`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	req, err := NewRequest(context.Background(), http.MethodDelete, "https://api.example.com/repos/1", nil,
+		WithIfMatch(`"v1"`, `"v2"`))
+	require.NoError(t, err)
+	assert.Equal(t, `"v1", "v2"`, req.Header.Get("If-Match"))
+}
+
+func TestRevalidator_MaxEntries(t *testing.T) {
+	version, notModified := &atomic.Int32{}, &atomic.Int32{}
+	version.Store(1)
+	c := NewWithTransport(etagServer(version, notModified)).WithRevalidator(NewRevalidator().WithMaxEntries(1))
+	ctx := context.Background()
+
+	for _, url := range []string{"https://api.example.com/a", "https://api.example.com/b", "https://api.example.com/a"} {
+		_, err := c.Get(ctx, url)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(0), notModified.Load())
+
+	_, err := c.Get(ctx, "https://api.example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), notModified.Load())
+}
+
+func TestRevalidator_MaxBodySize(t *testing.T) {
+	version, notModified := &atomic.Int32{}, &atomic.Int32{}
+	version.Store(1)
+	c := NewWithTransport(etagServer(version, notModified)).WithRevalidator(NewRevalidator().WithMaxBodySize(4))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(ctx, "https://api.example.com/a")
+		require.NoError(t, err)
+		assert.Equal(t, `body "v1"`, string(MustInterceptResponseBody(resp)))
+	}
+	assert.Equal(t, int32(0), notModified.Load())
+}
@@ -0,0 +1,228 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signer signs outgoing requests. Signing takes place after the request credentials have been applied,
+// right before the request is passed to the base transport.
+// The request passed to Sign is a clone owned by the Client, so it can be freely modified.
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc allows the use of ordinary functions as a Signer.
+type SignerFunc func(req *http.Request) error
+
+func (f SignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+const (
+	// DefaultHMACSignatureHeader is the header that carries the HMACSigner signature by default.
+	DefaultHMACSignatureHeader = "X-Signature"
+	// EmptyPayloadHash is the hex-encoded SHA-256 digest of an empty payload.
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// HMACSigner signs requests with an HMAC over the canonical form of the request.
+// By default, the canonical form is produced by CanonicalRequest, the HMAC uses SHA-256
+// and the hex-encoded signature is set as the value of DefaultHMACSignatureHeader.
+type HMACSigner struct {
+	Key []byte
+	// Hash overrides the hash function used for the HMAC, e.g. sha512.New.
+	Hash func() hash.Hash
+	// SignedHeaders are the names of the headers included in the signature.
+	// The Host header is always available, even though net/http does not keep it in the request headers.
+	SignedHeaders []string
+	// TimestampHeader, when set, is populated with the current Unix time before signing.
+	// Add the same header in SignedHeaders in order to protect against replay attacks.
+	TimestampHeader string
+	// SignatureHeader overrides DefaultHMACSignatureHeader.
+	SignatureHeader string
+	// Canonicalize overrides the canonical form of the request. The payload hash is the hex-encoded
+	// SHA-256 digest of the request body.
+	Canonicalize func(req *http.Request, signedHeaders []string, payloadHash string) (string, error)
+	// FormatSignature overrides the hex encoding of the signature, e.g. in order to add a key identifier.
+	FormatSignature func(signature []byte) string
+
+	now func() time.Time
+}
+
+func (s *HMACSigner) Sign(req *http.Request) error {
+	if s.TimestampHeader != "" {
+		now := time.Now
+		if s.now != nil {
+			now = s.now
+		}
+		req.Header.Set(s.TimestampHeader, fmt.Sprintf("%d", now().Unix()))
+	}
+	payloadHash, err := HashPayload(req)
+	if err != nil {
+		return err
+	}
+	canonicalize := CanonicalRequest
+	if s.Canonicalize != nil {
+		canonicalize = s.Canonicalize
+	}
+	canonical, err := canonicalize(req, s.SignedHeaders, payloadHash)
+	if err != nil {
+		return err
+	}
+	hashFunc := sha256.New
+	if s.Hash != nil {
+		hashFunc = s.Hash
+	}
+	mac := hmac.New(hashFunc, s.Key)
+	mac.Write([]byte(canonical))
+	signature := mac.Sum(nil)
+
+	header := DefaultHMACSignatureHeader
+	if s.SignatureHeader != "" {
+		header = s.SignatureHeader
+	}
+	if s.FormatSignature != nil {
+		req.Header.Set(header, s.FormatSignature(signature))
+	} else {
+		req.Header.Set(header, hex.EncodeToString(signature))
+	}
+	return nil
+}
+
+// CanonicalRequest is the default HMACSigner canonical form. It consists of the following lines:
+// the request method, the escaped URL path, the query string sorted by name and value,
+// one `name:value` line for each signed header (lowercase names, in the given order),
+// and finally the payload hash.
+func CanonicalRequest(req *http.Request, signedHeaders []string, payloadHash string) (string, error) {
+	lines := []string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req),
+	}
+	for _, name := range signedHeaders {
+		lines = append(lines, strings.ToLower(name)+":"+canonicalHeaderValue(req, name))
+	}
+	lines = append(lines, payloadHash)
+	return strings.Join(lines, "\n"), nil
+}
+
+// HashPayload returns the hex-encoded SHA-256 digest of the request body.
+// Replayable bodies (see http.Request.GetBody) are hashed from a fresh copy of the body stream.
+// Any other body is read in memory and replaced with a replayable body.
+func HashPayload(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return EmptyPayloadHash, nil
+	}
+	if req.GetBody == nil {
+		if err := bufferBody(req); err != nil {
+			return "", err
+		}
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bufferBody reads the request body in memory and makes it replayable.
+func bufferBody(req *http.Request) error {
+	b, err := InterceptRequestBody(req)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return nil
+}
+
+func canonicalHeaderValue(req *http.Request, name string) string {
+	if strings.EqualFold(name, "Host") {
+		if req.Host != "" {
+			return req.Host
+		}
+		return req.URL.Host
+	}
+	values := req.Header.Values(name)
+	trimmed := make([]string, len(values))
+	for i, v := range values {
+		trimmed[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// canonicalQuery encodes the query parameters sorted by encoded name and then by encoded value.
+// Spaces are encoded as `%20` instead of `+`.
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([][2]string, 0, len(query))
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{uriEncode(name, true), uriEncode(v, true)})
+		}
+	}
+	// Sorting the joined pairs would order `a-b=` before `a=`, since `-` sorts before `=`.
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode percent-encodes all characters except the RFC 3986 unreserved characters.
+// The slash character is optionally preserved.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type signTransport struct {
+	signer Signer
+	next   http.RoundTripper
+}
+
+func (t *signTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	signReq := req.Clone(req.Context())
+	if signReq.Header == nil {
+		signReq.Header = http.Header{}
+	}
+	if err := t.signer.Sign(signReq); err != nil {
+		return nil, newBaseError(err, ErrorTagSigning)
+	}
+	return t.next.RoundTrip(signReq)
+}
@@ -0,0 +1,156 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing is the breakdown of the duration of a request, collected with net/http/httptrace.
+// Phases that did not take place, e.g. DNS resolution and connection establishment for a reused connection,
+// have a zero duration.
+type Timing struct {
+	// DNS is the duration of the host name resolution.
+	DNS time.Duration
+	// Connect is the duration of the TCP connection establishment.
+	Connect time.Duration
+	// TLSHandshake is the duration of the TLS handshake.
+	TLSHandshake time.Duration
+	// Wait is the time from writing the request until the first response byte (server processing time).
+	Wait time.Duration
+	// Transfer is the time from the first response byte until the response body is read completely or closed.
+	Transfer time.Duration
+	// Total is the time from the start of the request until the end of the transfer.
+	Total time.Duration
+	// ConnectionReused reports whether the connection had been used for a previous request.
+	ConnectionReused bool
+}
+
+// WithTiming collects the Timing of the request, which is available through TimingFromResponse.
+func WithTiming() RequestParameter {
+	return func(opts *RequestParameters) {
+		opts.timing = true
+	}
+}
+
+// TimingFromResponse returns the Timing of a request made with the WithTiming functional option parameter
+// or by a Client configured with WithTiming. The Transfer phase is complete once the response body
+// has been read or closed.
+func TimingFromResponse(resp *http.Response) (Timing, bool) {
+	if resp == nil || resp.Request == nil {
+		return Timing{}, false
+	}
+	t, ok := resp.Request.Context().Value(timingKey{}).(*timingTrace)
+	if !ok {
+		return Timing{}, false
+	}
+	return t.timing(), true
+}
+
+type timingKey struct{}
+
+// timingTrace records the timestamps of the httptrace events. Hedged attempts of a request share the same trace,
+// so the events of the last attempt to reach each phase are recorded.
+type timingTrace struct {
+	now func() time.Time
+
+	mu                                sync.Mutex
+	start                             time.Time
+	dnsStart, dnsDone                 time.Time
+	connectStart, connectDone         time.Time
+	tlsStart, tlsDone                 time.Time
+	gotConn                           time.Time
+	wroteRequest, firstByte, bodyDone time.Time
+	reused                            bool
+}
+
+func withTimingTrace(req *http.Request, now func() time.Time) *http.Request {
+	t := &timingTrace{now: now, start: now()}
+	ctx := httptrace.WithClientTrace(context.WithValue(req.Context(), timingKey{}, t), t.clientTrace())
+	return req.WithContext(ctx)
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	set := func(field *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*field = t.now()
+	}
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = t.now()
+			t.reused = info.Reused
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { set(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { set(&t.dnsDone) },
+		ConnectStart:         func(string, string) { set(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { set(&t.connectDone) },
+		TLSHandshakeStart:    func() { set(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { set(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { set(&t.firstByte) },
+	}
+}
+
+func (t *timingTrace) timing() Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.bodyDone
+	if end.IsZero() {
+		end = t.now()
+	}
+	timing := Timing{
+		DNS:              between(t.dnsStart, t.dnsDone),
+		Connect:          between(t.connectStart, t.connectDone),
+		TLSHandshake:     between(t.tlsStart, t.tlsDone),
+		Wait:             between(t.wroteRequest, t.firstByte),
+		ConnectionReused: t.reused,
+	}
+	if !t.firstByte.IsZero() {
+		timing.Transfer = between(t.firstByte, end)
+	}
+	timing.Total = between(t.start, end)
+	return timing
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// timedBody marks the end of the transfer when the body is read completely or closed.
+type timedBody struct {
+	io.ReadCloser
+	trace *timingTrace
+	once  sync.Once
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+func (b *timedBody) done() {
+	b.once.Do(func() {
+		b.trace.mu.Lock()
+		defer b.trace.mu.Unlock()
+		b.trace.bodyDone = b.trace.now()
+	})
+}
@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WithTiming(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+	c := NewWithTransport(srv.Client().Transport).WithTiming()
+
+	resp, err := c.Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	timing, ok := TimingFromResponse(resp)
+	require.True(t, ok)
+	assert.False(t, timing.ConnectionReused)
+	assert.Positive(t, timing.Connect)
+	assert.Positive(t, timing.TLSHandshake)
+	assert.GreaterOrEqual(t, timing.Wait, 20*time.Millisecond)
+	assert.GreaterOrEqual(t, timing.Total, timing.Connect+timing.TLSHandshake+timing.Wait+timing.Transfer)
+
+	resp, err = c.Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	timing, ok = TimingFromResponse(resp)
+	require.True(t, ok)
+	assert.True(t, timing.ConnectionReused)
+	assert.Zero(t, timing.Connect)
+	assert.Zero(t, timing.TLSHandshake)
+
+	// The Transfer phase is complete once the body is closed.
+	again, _ := TimingFromResponse(resp)
+	assert.Equal(t, timing, again)
+}
+
+func TestWithTiming(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("OK"))
+	}))
+	defer srv.Close()
+	c := NewWithTransport(srv.Client().Transport)
+
+	resp, err := c.Get(context.Background(), srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	_, ok := TimingFromResponse(resp)
+	assert.False(t, ok)
+
+	resp, err = c.Get(context.Background(), srv.URL, WithTiming())
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	timing, ok := TimingFromResponse(resp)
+	assert.True(t, ok)
+	assert.Zero(t, timing.TLSHandshake)
+	assert.Positive(t, timing.Total)
+}
@@ -0,0 +1,308 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span in a trace, as propagated by the W3C Trace Context headers.
+// See https://www.w3.org/TR/trace-context/.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether both the trace and span identifiers are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats the span context as a `traceparent` header value.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceParent parses a `traceparent` header value. Future versions of the header are parsed
+// according to the version 00 format, as required by the specification.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || parts[0] == "00" && len(parts) != 4 ||
+		len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	version, errVersion := hex.DecodeString(parts[0])
+	_, errTraceID := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, errSpanID := hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	flags, errFlags := hex.DecodeString(parts[3])
+	if err := errors.Join(errVersion, errTraceID, errSpanID, errFlags); err != nil || version[0] == 0xff || !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of the context that carries the given span context.
+// Requests made with the returned context create client spans as children of that span.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by the context, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// SpanStatus is the status of a finished span.
+type SpanStatus int
+
+const (
+	SpanStatusUnset SpanStatus = iota
+	SpanStatusError
+)
+
+// Span is a finished client span.
+type Span struct {
+	Name              string
+	SpanContext       SpanContext
+	Parent            SpanContext
+	Start             time.Time
+	End               time.Time
+	Attributes        map[string]any
+	Status            SpanStatus
+	StatusDescription string
+}
+
+// SpanExporter receives the sampled spans once they are finished. Implementations must be safe for concurrent use.
+type SpanExporter interface {
+	ExportSpan(ctx context.Context, span Span)
+}
+
+// SpanExporterFunc allows the use of ordinary functions as a SpanExporter.
+type SpanExporterFunc func(ctx context.Context, span Span)
+
+func (f SpanExporterFunc) ExportSpan(ctx context.Context, span Span) {
+	f(ctx, span)
+}
+
+// Tracer creates a client span for every request attempt and propagates it with the `traceparent` and `tracestate`
+// headers. Spans follow the OpenTelemetry semantic conventions for HTTP clients: they are named after the request
+// method and route template (see WithRouteTemplate), and carry the `http.request.method`, `url.full`, `url.template`,
+// `server.address`, `server.port`, `http.response.status_code`, `network.protocol.version`,
+// `http.request.resend_count` and `error.type` attributes.
+// Errors tagged by the Client are recorded under the `httpclient.error.tags` attribute.
+//
+// The parent span is taken from the request context (see ContextWithSpanContext) or from an existing `traceparent`
+// header. Calls without a parent start a new, sampled trace with a span that covers the whole call,
+// from the first attempt until the response headers are received, and is the parent of every attempt span.
+type Tracer struct {
+	exporter SpanExporter
+	now      func() time.Time
+}
+
+// NewTracer creates a Tracer that sends finished spans to the given SpanExporter.
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{exporter: exporter, now: time.Now}
+}
+
+// Middleware returns the Middleware that traces requests.
+func (t *Tracer) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			parent, ok := SpanContextFromContext(req.Context())
+			if !ok {
+				var err error
+				if parent, err = ParseTraceParent(req.Header.Get("Traceparent")); err == nil {
+					parent.TraceState = req.Header.Get("Tracestate")
+				}
+			}
+			sc := SpanContext{TraceID: parent.TraceID, Sampled: true, TraceState: parent.TraceState}
+			if parent.IsValid() {
+				sc.Sampled = parent.Sampled
+			} else {
+				parent = t.callSpan(req)
+				sc.TraceID = parent.TraceID
+				sc.TraceState = ""
+				if !parent.IsValid() {
+					binaryRandom(sc.TraceID[:])
+				}
+			}
+			binaryRandom(sc.SpanID[:])
+
+			span := Span{
+				Name:        spanName(req),
+				SpanContext: sc,
+				Parent:      parent,
+				Start:       t.now(),
+				Attributes:  requestSpanAttributes(req),
+			}
+			r := req.Clone(ContextWithSpanContext(req.Context(), sc))
+			r.Header.Set("Traceparent", sc.TraceParent())
+			if sc.TraceState != "" {
+				r.Header.Set("Tracestate", sc.TraceState)
+			}
+
+			resp, err := next.RoundTrip(r)
+			span.End = t.now()
+			recordResult(&span, resp, err)
+			if sc.Sampled {
+				t.exporter.ExportSpan(req.Context(), span)
+			}
+			return resp, err
+		})
+	}
+}
+
+// callSpan returns the span of the call that the request belongs to, starting it on the first attempt.
+// Requests that are not sent by a Client, e.g. through the Middleware alone, have no call span.
+func (t *Tracer) callSpan(req *http.Request) SpanContext {
+	call, ok := req.Context().Value(callTraceKey{}).(*callTrace)
+	if !ok {
+		return SpanContext{}
+	}
+	call.once.Do(func() {
+		span := Span{
+			Name:        spanName(req),
+			SpanContext: SpanContext{Sampled: true},
+			Start:       t.now(),
+			Attributes:  requestSpanAttributes(req),
+		}
+		delete(span.Attributes, "http.request.resend_count")
+		binaryRandom(span.SpanContext.TraceID[:])
+		binaryRandom(span.SpanContext.SpanID[:])
+		ctx := context.WithoutCancel(req.Context())
+		call.span = span.SpanContext
+		call.end = func(resp *http.Response, err error) {
+			span.End = t.now()
+			recordResult(&span, resp, err)
+			t.exporter.ExportSpan(ctx, span)
+		}
+	})
+	return call.span
+}
+
+type callTraceKey struct{}
+
+// callTrace is the per-call tracing state, created by Client.Do and shared by all the attempts of the call.
+type callTrace struct {
+	once sync.Once
+	span SpanContext
+	end  func(resp *http.Response, err error)
+}
+
+// withCallTrace returns a copy of the request that carries a new callTrace.
+func withCallTrace(req *http.Request) (*http.Request, *callTrace) {
+	call := &callTrace{}
+	return req.WithContext(context.WithValue(req.Context(), callTraceKey{}, call)), call
+}
+
+// finish ends the call span, if any attempt started it. No attempt may start it afterwards.
+func (c *callTrace) finish(resp *http.Response, err error) {
+	c.once.Do(func() {})
+	if c.end != nil {
+		c.end(resp, err)
+	}
+}
+
+func spanName(req *http.Request) string {
+	if route := RouteTemplate(req); route != "" {
+		return req.Method + " " + route
+	}
+	return req.Method
+}
+
+func requestSpanAttributes(req *http.Request) map[string]any {
+	u := *req.URL
+	u.User = nil
+	attrs := map[string]any{
+		"http.request.method": req.Method,
+		"url.full":            u.String(),
+		"server.address":      u.Hostname(),
+	}
+	port := u.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs["server.port"] = p
+	}
+	if attempt := Attempt(req); attempt > 0 {
+		attrs["http.request.resend_count"] = attempt
+	}
+	if route := RouteTemplate(req); route != "" {
+		attrs["url.template"] = route
+	}
+	return attrs
+}
+
+func recordResult(span *Span, resp *http.Response, err error) {
+	if err != nil {
+		span.Status = SpanStatusError
+		span.StatusDescription = err.Error()
+		span.Attributes["error.type"] = errorType(err)
+		var baseErr *BaseError
+		if errors.As(err, &baseErr) && len(baseErr.tags) > 0 {
+			tags := make([]string, len(baseErr.tags))
+			for i, tag := range baseErr.tags {
+				tags[i] = string(tag)
+			}
+			span.Attributes["httpclient.error.tags"] = tags
+		}
+		return
+	}
+	span.Attributes["http.response.status_code"] = resp.StatusCode
+	if resp.ProtoMajor > 0 {
+		version := strconv.Itoa(resp.ProtoMajor)
+		if resp.ProtoMajor == 1 {
+			version += "." + strconv.Itoa(resp.ProtoMinor)
+		}
+		span.Attributes["network.protocol.version"] = version
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.Status = SpanStatusError
+		span.Attributes["error.type"] = strconv.Itoa(resp.StatusCode)
+	}
+}
+
+// errorType returns a low-cardinality description of the error: the tags of the Client errors,
+// or the kind of common transport errors.
+func errorType(err error) string {
+	var baseErr *BaseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &baseErr) && len(baseErr.tags) > 0:
+		return baseErr.tags.String("/")
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "_OTHER"
+	}
+}
+
+func binaryRandom(b []byte) {
+	for i := range b {
+		b[i] = byte(rand.Uint32())
+	}
+}